@@ -0,0 +1,358 @@
+package backup
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/sebastian-mora/basicOTP"
+	"github.com/sebastian-mora/basicOTP/internal/kdf"
+)
+
+// aegisSlotPassword is the slot type of a password derived key slot.
+const aegisSlotPassword = 1
+
+// Scrypt parameters used by Aegis for new password slots.
+const (
+	aegisScryptN = 1 << 15
+	aegisScryptR = 8
+	aegisScryptP = 1
+)
+
+// aegisAlgorithms are the hash algorithms Aegis can read.
+var aegisAlgorithms = map[basicOTP.HashType]bool{
+	basicOTP.SHA1:   true,
+	basicOTP.SHA256: true,
+	basicOTP.SHA512: true,
+}
+
+// aegisVault is an Aegis vault export. The db is either an aegisDB
+// object or, for encrypted vaults, a base64 string of its ciphertext.
+type aegisVault struct {
+	Version int             `json:"version"`
+	Header  aegisHeader     `json:"header"`
+	DB      json.RawMessage `json:"db"`
+}
+
+// aegisHeader holds the key slots and database encryption parameters.
+// Both are null for plain vaults.
+type aegisHeader struct {
+	Slots  []aegisSlot  `json:"slots"`
+	Params *aegisParams `json:"params"`
+}
+
+// aegisSlot holds the master key encrypted with a key derived from the password.
+type aegisSlot struct {
+	Type      int         `json:"type"`
+	UUID      string      `json:"uuid"`
+	Key       string      `json:"key"`
+	KeyParams aegisParams `json:"key_params"`
+	N         int         `json:"n"`
+	R         int         `json:"r"`
+	P         int         `json:"p"`
+	Salt      string      `json:"salt"`
+	Repaired  bool        `json:"repaired"`
+	IsBackup  bool        `json:"is_backup"`
+}
+
+// aegisParams are the AES-GCM nonce and tag, hex encoded.
+type aegisParams struct {
+	Nonce string `json:"nonce"`
+	Tag   string `json:"tag"`
+}
+
+// aegisDB is the decrypted vault content.
+type aegisDB struct {
+	Version int          `json:"version"`
+	Entries []aegisEntry `json:"entries"`
+}
+
+// aegisEntry is an account in an Aegis vault.
+type aegisEntry struct {
+	Type   string    `json:"type"`
+	UUID   string    `json:"uuid"`
+	Name   string    `json:"name"`
+	Issuer string    `json:"issuer"`
+	Note   string    `json:"note"`
+	Icon   *string   `json:"icon"`
+	Info   aegisInfo `json:"info"`
+}
+
+// aegisInfo holds the OTP parameters of an entry.
+type aegisInfo struct {
+	Secret  string `json:"secret"`
+	Algo    string `json:"algo"`
+	Digits  int    `json:"digits"`
+	Period  int    `json:"period,omitempty"`
+	Counter *int   `json:"counter,omitempty"`
+}
+
+// ImportAegis reads an Aegis vault export. Plain vaults ignore the password,
+// encrypted vaults are unlocked with the first password slot that accepts it.
+// Steam, Yandex and mOTP entries are reported as unsupported.
+func ImportAegis(data []byte, password []byte) (Import, error) {
+	var vault aegisVault
+	if err := json.Unmarshal(data, &vault); err != nil {
+		return Import{}, err
+	}
+
+	dbJSON := []byte(vault.DB)
+	if vault.Header.Params != nil {
+		if password == nil {
+			return Import{}, ErrEncrypted
+		}
+
+		var err error
+		dbJSON, err = decryptAegisDB(vault, password)
+		if err != nil {
+			return Import{}, err
+		}
+	}
+
+	var db aegisDB
+	if err := json.Unmarshal(dbJSON, &db); err != nil {
+		return Import{}, err
+	}
+
+	var result Import
+	for index, e := range db.Entries {
+		counter := 0
+		if e.Info.Counter != nil {
+			counter = *e.Info.Counter
+		}
+
+		key, err := newKey(e.Type, e.Name, e.Issuer, e.Info.Algo, e.Info.Digits, e.Info.Period, counter)
+		if err == nil {
+			key.Secret, err = decodeSecret(e.Info.Secret)
+		}
+		result.add(index, key, err)
+	}
+	return result, nil
+}
+
+// decryptAegisDB unlocks the master key with password and decrypts the vault database.
+func decryptAegisDB(vault aegisVault, password []byte) ([]byte, error) {
+	var encoded string
+	if err := json.Unmarshal(vault.DB, &encoded); err != nil {
+		return nil, fmt.Errorf("backup: encrypted db is not a string: %w", err)
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, slot := range vault.Header.Slots {
+		if slot.Type != aegisSlotPassword {
+			continue
+		}
+
+		salt, err := hex.DecodeString(slot.Salt)
+		if err != nil {
+			return nil, err
+		}
+
+		derived, err := kdf.Scrypt(password, salt, slot.N, slot.R, slot.P, 32)
+		if err != nil {
+			return nil, err
+		}
+
+		encryptedKey, err := hex.DecodeString(slot.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		masterKey, err := aesGCMOpen(derived, slot.KeyParams, encryptedKey)
+		if err != nil {
+			continue // the password does not unlock this slot
+		}
+
+		return aesGCMOpen(masterKey, *vault.Header.Params, ciphertext)
+	}
+
+	return nil, ErrWrongPassword
+}
+
+// ExportAegis serializes entries into an Aegis vault export.
+// A nil password produces a plain vault, otherwise the vault is encrypted
+// with a random master key stored in a scrypt-derived password slot.
+// Only SHA1, SHA256 and SHA512 entries are supported by Aegis.
+func ExportAegis(entries []Entry, password []byte) ([]byte, error) {
+	db := aegisDB{Version: 2, Entries: make([]aegisEntry, 0, len(entries))}
+	for _, entry := range entries {
+		key := entry.Key()
+		if !aegisAlgorithms[key.HashType] {
+			return nil, fmt.Errorf("%w %q", ErrUnsupportedAlgorithm, key.HashType)
+		}
+
+		uuid, err := newUUID()
+		if err != nil {
+			return nil, err
+		}
+
+		info := aegisInfo{
+			Secret: encodeSecret(key.Secret),
+			Algo:   string(key.HashType),
+			Digits: key.CodeLength,
+		}
+		if key.Type == "totp" {
+			info.Period = key.Period
+		} else {
+			counter := key.Counter
+			info.Counter = &counter
+		}
+
+		db.Entries = append(db.Entries, aegisEntry{
+			Type:   key.Type,
+			UUID:   uuid,
+			Name:   key.Label,
+			Issuer: key.Issuer,
+			Info:   info,
+		})
+	}
+
+	dbJSON, err := json.Marshal(db)
+	if err != nil {
+		return nil, err
+	}
+
+	vault := aegisVault{Version: 1, DB: dbJSON}
+	if password != nil {
+		vault, err = encryptAegisDB(dbJSON, password)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return json.MarshalIndent(vault, "", "    ")
+}
+
+// encryptAegisDB encrypts the vault database with a random master key
+// and stores the master key in a single password slot.
+func encryptAegisDB(dbJSON []byte, password []byte) (aegisVault, error) {
+	masterKey, err := randomBytes(32)
+	if err != nil {
+		return aegisVault{}, err
+	}
+	salt, err := randomBytes(32)
+	if err != nil {
+		return aegisVault{}, err
+	}
+
+	derived, err := kdf.Scrypt(password, salt, aegisScryptN, aegisScryptR, aegisScryptP, 32)
+	if err != nil {
+		return aegisVault{}, err
+	}
+
+	encryptedKey, keyParams, err := aesGCMSeal(derived, masterKey)
+	if err != nil {
+		return aegisVault{}, err
+	}
+	ciphertext, dbParams, err := aesGCMSeal(masterKey, dbJSON)
+	if err != nil {
+		return aegisVault{}, err
+	}
+
+	uuid, err := newUUID()
+	if err != nil {
+		return aegisVault{}, err
+	}
+
+	db, err := json.Marshal(base64.StdEncoding.EncodeToString(ciphertext))
+	if err != nil {
+		return aegisVault{}, err
+	}
+
+	return aegisVault{
+		Version: 1,
+		Header: aegisHeader{
+			Slots: []aegisSlot{{
+				Type:      aegisSlotPassword,
+				UUID:      uuid,
+				Key:       hex.EncodeToString(encryptedKey),
+				KeyParams: keyParams,
+				N:         aegisScryptN,
+				R:         aegisScryptR,
+				P:         aegisScryptP,
+				Salt:      hex.EncodeToString(salt),
+				Repaired:  true,
+			}},
+			Params: &dbParams,
+		},
+		DB: db,
+	}, nil
+}
+
+// aesGCMSeal encrypts plaintext with a random nonce, returning the tag separately in params.
+func aesGCMSeal(key []byte, plaintext []byte) ([]byte, aegisParams, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, aegisParams{}, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, aegisParams{}, err
+	}
+
+	nonce, err := randomBytes(gcm.NonceSize())
+	if err != nil {
+		return nil, aegisParams{}, err
+	}
+
+	sealed := gcm.Seal(nil, nonce, plaintext, nil)
+	ciphertext, tag := sealed[:len(sealed)-gcm.Overhead()], sealed[len(sealed)-gcm.Overhead():]
+	return ciphertext, aegisParams{Nonce: hex.EncodeToString(nonce), Tag: hex.EncodeToString(tag)}, nil
+}
+
+// aesGCMOpen decrypts ciphertext whose tag is stored separately in params.
+func aesGCMOpen(key []byte, params aegisParams, ciphertext []byte) ([]byte, error) {
+	nonce, err := hex.DecodeString(params.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	tag, err := hex.DecodeString(params.Tag)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(nonce))
+	if err != nil {
+		return nil, err
+	}
+	if len(tag) != gcm.Overhead() {
+		return nil, errors.New("backup: invalid AES-GCM tag length")
+	}
+
+	sealed := append(append([]byte(nil), ciphertext...), tag...)
+	return gcm.Open(nil, nonce, sealed, nil)
+}
+
+// randomBytes returns n bytes from crypto/rand.
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// newUUID returns a random version 4 UUID.
+func newUUID() (string, error) {
+	b, err := randomBytes(16)
+	if err != nil {
+		return "", err
+	}
+
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
@@ -0,0 +1,178 @@
+package backup_test
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/sebastian-mora/basicOTP"
+	"github.com/sebastian-mora/basicOTP/backup"
+	"github.com/sebastian-mora/basicOTP/internal/kdf"
+)
+
+const aegisPlainDB = `{
+	"version":2,
+	"entries":[
+		{"type":"totp","uuid":"3ae6f1ad-2e65-4ed2-a953-1ec0dff2386d","name":"alice","issuer":"Example","note":"","icon":null,"info":{"secret":"` + testSecret + `","algo":"SHA1","digits":8,"period":30}},
+		{"type":"steam","uuid":"9e1d1a6e-6d1b-4b8a-9b3c-4a6f5e2b7c11","name":"bob","issuer":"Steam","note":"","icon":null,"info":{"secret":"` + testSecret + `","algo":"SHA1","digits":5,"period":30}},
+		{"type":"hotp","uuid":"c4b2d7a0-5b1f-4f0e-8c5d-2f8a9e6b3d12","name":"carol","issuer":"Example","note":"","icon":null,"info":{"secret":"` + testSecret + `","algo":"SHA1","digits":6,"counter":1}}
+	]
+}`
+
+func TestImportAegisPlain(t *testing.T) {
+	data := []byte(`{"version":1,"header":{"slots":null,"params":null},"db":` + aegisPlainDB + `}`)
+
+	result, err := backup.ImportAegis(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Entries) != 2 {
+		t.Fatalf("Expected 2 entries, Got: %d", len(result.Entries))
+	}
+
+	checkTOTP(t, result.Entries[0], "alice", "Example")
+	checkHOTP(t, result.Entries[1], "carol")
+	checkUnsupported(t, result, 1)
+}
+
+// sealAegis encrypts plaintext with AES-GCM returning hex nonce, hex tag and ciphertext.
+func sealAegis(t *testing.T, key []byte, nonce []byte, plaintext []byte) (string, string, []byte) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealed := gcm.Seal(nil, nonce, plaintext, nil)
+	ciphertext, tag := sealed[:len(sealed)-16], sealed[len(sealed)-16:]
+	return hex.EncodeToString(nonce), hex.EncodeToString(tag), ciphertext
+}
+
+func TestImportAegisEncrypted(t *testing.T) {
+	password := []byte("correct horse battery staple")
+	salt := []byte("0123456789abcdef0123456789abcdef")
+	masterKey := []byte("fedcba9876543210fedcba9876543210")
+
+	derived, err := kdf.Scrypt(password, salt, 1024, 8, 1, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyNonce, keyTag, encryptedKey := sealAegis(t, derived, []byte("slot-nonce12"), masterKey)
+	dbNonce, dbTag, encryptedDB := sealAegis(t, masterKey, []byte("vault-nonce1"), []byte(aegisPlainDB))
+
+	data := []byte(`{
+		"version":1,
+		"header":{
+			"slots":[
+				{"type":2,"uuid":"biometric","key":"00","key_params":{"nonce":"00","tag":"00"}},
+				{"type":1,"uuid":"5b3c7e2a-1f4d-4c6b-9a8e-0d2f6e1b7c35","key":"` + hex.EncodeToString(encryptedKey) + `","key_params":{"nonce":"` + keyNonce + `","tag":"` + keyTag + `"},"n":1024,"r":8,"p":1,"salt":"` + hex.EncodeToString(salt) + `","repaired":true,"is_backup":false}
+			],
+			"params":{"nonce":"` + dbNonce + `","tag":"` + dbTag + `"}
+		},
+		"db":"` + base64.StdEncoding.EncodeToString(encryptedDB) + `"
+	}`)
+
+	result, err := backup.ImportAegis(data, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Entries) != 2 {
+		t.Fatalf("Expected 2 entries, Got: %d", len(result.Entries))
+	}
+	checkTOTP(t, result.Entries[0], "alice", "Example")
+	checkHOTP(t, result.Entries[1], "carol")
+
+	if _, err := backup.ImportAegis(data, []byte("wrong")); !errors.Is(err, backup.ErrWrongPassword) {
+		t.Errorf("Expected ErrWrongPassword, Got: %v", err)
+	}
+	if _, err := backup.ImportAegis(data, nil); !errors.Is(err, backup.ErrEncrypted) {
+		t.Errorf("Expected ErrEncrypted, Got: %v", err)
+	}
+
+	// A slot requesting 128 GiB of memory is rejected before deriving the key
+	hostile := bytes.Replace(data, []byte(`"n":1024,"r":8,"p":1`), []byte(`"n":1048576,"r":1024,"p":1073741823`), 1)
+	if _, err := backup.ImportAegis(hostile, password); err == nil || errors.Is(err, backup.ErrWrongPassword) {
+		t.Errorf("Expected an error for hostile scrypt parameters, Got: %v", err)
+	}
+}
+
+// exportEntries returns a TOTP and a HOTP entry using the RFC test secret.
+func exportEntries() []backup.Entry {
+	secret := []byte("12345678901234567890")
+	return []backup.Entry{
+		{
+			Label:  "alice",
+			Issuer: "Example",
+			TOTP:   basicOTP.NewTOTP(basicOTP.TOTPConfig{CodeLength: 8, HashType: basicOTP.SHA1, Secret: secret}),
+		},
+		{
+			Label:  "carol",
+			Issuer: "Example",
+			HOTP:   basicOTP.NewHTOP(basicOTP.HOTPConfig{CodeLength: 6, HashType: basicOTP.SHA1, Secret: secret, Counter: 1}),
+		},
+	}
+}
+
+func TestExportAegisPlainRoundTrip(t *testing.T) {
+	data, err := backup.ExportAegis(exportEntries(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := backup.ImportAegis(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Entries) != 2 || len(result.Errors) != 0 {
+		t.Fatalf("Expected 2 entries, Got: %+v", result)
+	}
+
+	checkTOTP(t, result.Entries[0], "alice", "Example")
+	checkHOTP(t, result.Entries[1], "carol")
+}
+
+func TestExportAegisEncryptedRoundTrip(t *testing.T) {
+	password := []byte("correct horse battery staple")
+	data, err := backup.ExportAegis(exportEntries(), password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(data, []byte(testSecret)) || bytes.Contains(data, []byte("alice")) {
+		t.Error("Encrypted export contains plaintext account data")
+	}
+
+	result, err := backup.ImportAegis(data, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Entries) != 2 {
+		t.Fatalf("Expected 2 entries, Got: %d", len(result.Entries))
+	}
+
+	checkTOTP(t, result.Entries[0], "alice", "Example")
+	checkHOTP(t, result.Entries[1], "carol")
+
+	if _, err := backup.ImportAegis(data, []byte("wrong")); !errors.Is(err, backup.ErrWrongPassword) {
+		t.Errorf("Expected ErrWrongPassword, Got: %v", err)
+	}
+}
+
+func TestExportAegisUnsupportedAlgorithm(t *testing.T) {
+	entries := []backup.Entry{{
+		Label: "alice",
+		TOTP:  basicOTP.NewTOTP(basicOTP.TOTPConfig{HashType: basicOTP.SHA384, Secret: []byte("test")}),
+	}}
+
+	if _, err := backup.ExportAegis(entries, nil); !errors.Is(err, backup.ErrUnsupportedAlgorithm) {
+		t.Errorf("Expected ErrUnsupportedAlgorithm, Got: %v", err)
+	}
+}
@@ -0,0 +1,36 @@
+package backup
+
+import (
+	"encoding/json"
+)
+
+// andOTPEntry is an account in an andOTP plain JSON backup.
+type andOTPEntry struct {
+	Secret    string `json:"secret"`
+	Issuer    string `json:"issuer"`
+	Label     string `json:"label"`
+	Digits    int    `json:"digits"`
+	Type      string `json:"type"`
+	Algorithm string `json:"algorithm"`
+	Period    int    `json:"period"`
+	Counter   int    `json:"counter"`
+}
+
+// ImportAndOTP reads an unencrypted andOTP JSON backup.
+// STEAM and MOTP entries are reported as unsupported.
+func ImportAndOTP(data []byte) (Import, error) {
+	var entries []andOTPEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return Import{}, err
+	}
+
+	var result Import
+	for index, e := range entries {
+		key, err := newKey(e.Type, e.Label, e.Issuer, e.Algorithm, e.Digits, e.Period, e.Counter)
+		if err == nil {
+			key.Secret, err = decodeSecret(e.Secret)
+		}
+		result.add(index, key, err)
+	}
+	return result, nil
+}
@@ -0,0 +1,129 @@
+// Package backup imports and exports OTP accounts in the backup files of other
+// authenticator apps, such as andOTP, Aegis, 2FAS and FreeOTP+.
+// Entries that cannot be represented by basicOTP are reported individually
+// instead of failing the whole import.
+package backup
+
+import (
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sebastian-mora/basicOTP"
+)
+
+var (
+	ErrUnsupportedType      = errors.New("backup: unsupported entry type")
+	ErrUnsupportedAlgorithm = errors.New("backup: unsupported algorithm")
+	ErrInvalidSecret        = errors.New("backup: invalid secret")
+	ErrEncrypted            = errors.New("backup: file is encrypted")
+	ErrWrongPassword        = errors.New("backup: wrong password")
+)
+
+// Entry is a single imported account.
+// Exactly one of TOTP and HOTP is set.
+type Entry struct {
+	Label  string         // Label is the account name.
+	Issuer string         // Issuer is the provider or service the account belongs to.
+	TOTP   *basicOTP.TOTP // TOTP is the generator of a time-based entry.
+	HOTP   *basicOTP.HTOP // HOTP is the generator of a counter-based entry.
+}
+
+// Key returns the parameters of the entry's generator.
+func (e Entry) Key() basicOTP.Key {
+	if e.TOTP != nil {
+		return e.TOTP.Key(e.Label, e.Issuer)
+	}
+	return e.HOTP.Key(e.Label, e.Issuer)
+}
+
+// EntryError reports an entry that could not be imported.
+type EntryError struct {
+	Index int    // Index is the position of the entry in the backup file.
+	Label string // Label is the account name of the entry, if known.
+	Err   error  // Err is the reason the entry was skipped.
+}
+
+// Error implements the error interface.
+func (e *EntryError) Error() string {
+	return fmt.Sprintf("backup: entry %d (%s): %v", e.Index, e.Label, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *EntryError) Unwrap() error {
+	return e.Err
+}
+
+// Import is the result of reading a backup file.
+type Import struct {
+	Entries []Entry       // Entries are the accounts that were imported.
+	Errors  []*EntryError // Errors are the entries that were skipped.
+}
+
+// add converts key to an Entry, recording an EntryError if it is not supported.
+func (i *Import) add(index int, key basicOTP.Key, err error) {
+	if err == nil {
+		var entry Entry
+		entry, err = newEntry(key)
+		if err == nil {
+			i.Entries = append(i.Entries, entry)
+			return
+		}
+	}
+	i.Errors = append(i.Errors, &EntryError{Index: index, Label: key.Label, Err: err})
+}
+
+// newEntry creates the generator described by key.
+func newEntry(key basicOTP.Key) (Entry, error) {
+	if len(key.Secret) == 0 {
+		return Entry{}, ErrInvalidSecret
+	}
+
+	entry := Entry{Label: key.Label, Issuer: key.Issuer}
+	switch key.Type {
+	case "totp":
+		entry.TOTP = key.TOTP()
+	case "hotp":
+		entry.HOTP = key.HOTP()
+	default:
+		return Entry{}, fmt.Errorf("%w %q", ErrUnsupportedType, key.Type)
+	}
+	return entry, nil
+}
+
+// newKey builds a Key from the fields common to all backup formats.
+func newKey(otpType string, label string, issuer string, algorithm string, digits int, period int, counter int) (basicOTP.Key, error) {
+	key := basicOTP.Key{
+		Type:       strings.ToLower(otpType),
+		Label:      label,
+		Issuer:     issuer,
+		HashType:   basicOTP.SHA1,
+		CodeLength: digits,
+		Period:     period,
+		Counter:    counter,
+	}
+
+	if algorithm != "" {
+		key.HashType = basicOTP.HashType(strings.ToUpper(algorithm))
+		if _, ok := basicOTP.LookupHash(key.HashType); !ok {
+			return key, fmt.Errorf("%w %q", ErrUnsupportedAlgorithm, algorithm)
+		}
+	}
+	return key, nil
+}
+
+// decodeSecret decodes a Base32 secret with or without padding.
+func decodeSecret(secret string) ([]byte, error) {
+	secret = strings.TrimRight(strings.ToUpper(strings.ReplaceAll(secret, " ", "")), "=")
+	decoded, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil || len(decoded) == 0 {
+		return nil, ErrInvalidSecret
+	}
+	return decoded, nil
+}
+
+// encodeSecret encodes a secret in Base32 without padding.
+func encodeSecret(secret []byte) string {
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(secret)
+}
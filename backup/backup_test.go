@@ -0,0 +1,142 @@
+package backup_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sebastian-mora/basicOTP"
+	"github.com/sebastian-mora/basicOTP/backup"
+)
+
+// The secret used in every fixture is "12345678901234567890",
+// the RFC 4226 test secret, in Base32.
+const testSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// checkTOTP verifies an imported TOTP generates the RFC 6238 SHA1 code for 59 seconds.
+func checkTOTP(t *testing.T, entry backup.Entry, label string, issuer string) {
+	t.Helper()
+	if entry.Label != label || entry.Issuer != issuer {
+		t.Errorf("Expected %s/%s, Got: %s/%s", label, issuer, entry.Label, entry.Issuer)
+	}
+	if entry.TOTP == nil || entry.HOTP != nil {
+		t.Fatalf("Expected a TOTP entry, Got: %+v", entry)
+	}
+	if code := entry.TOTP.GenerateAt(59); code != "94287082" {
+		t.Errorf("Expected code 94287082, Got: %s", code)
+	}
+}
+
+// checkHOTP verifies an imported HOTP is at counter 1 of the RFC 4226 test data.
+func checkHOTP(t *testing.T, entry backup.Entry, label string) {
+	t.Helper()
+	if entry.Label != label {
+		t.Errorf("Expected label %s, Got: %s", label, entry.Label)
+	}
+	if entry.HOTP == nil || entry.TOTP != nil {
+		t.Fatalf("Expected a HOTP entry, Got: %+v", entry)
+	}
+	if code := entry.HOTP.Generate(); code != "287082" {
+		t.Errorf("Expected code 287082, Got: %s", code)
+	}
+}
+
+// checkUnsupported verifies the import skipped exactly one unsupported entry.
+func checkUnsupported(t *testing.T, result backup.Import, index int) {
+	t.Helper()
+	if len(result.Errors) != 1 {
+		t.Fatalf("Expected 1 entry error, Got: %v", result.Errors)
+	}
+	if result.Errors[0].Index != index || !errors.Is(result.Errors[0], backup.ErrUnsupportedType) {
+		t.Errorf("Unexpected entry error: %v", result.Errors[0])
+	}
+}
+
+func TestImportAndOTP(t *testing.T) {
+	data := []byte(`[
+		{"secret":"` + testSecret + `","issuer":"Example","label":"alice","digits":8,"type":"TOTP","algorithm":"SHA1","thumbnail":"Default","last_used":0,"used_frequency":0,"period":30,"tags":[]},
+		{"secret":"` + testSecret + `","issuer":"Steam","label":"bob","digits":5,"type":"STEAM","algorithm":"SHA1","period":30,"tags":[]},
+		{"secret":"` + testSecret + `","issuer":"Example","label":"carol","digits":6,"type":"HOTP","algorithm":"SHA1","counter":1,"tags":[]}
+	]`)
+
+	result, err := backup.ImportAndOTP(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Entries) != 2 {
+		t.Fatalf("Expected 2 entries, Got: %d", len(result.Entries))
+	}
+
+	checkTOTP(t, result.Entries[0], "alice", "Example")
+	checkHOTP(t, result.Entries[1], "carol")
+	checkUnsupported(t, result, 1)
+}
+
+func TestImportTwoFAS(t *testing.T) {
+	data := []byte(`{
+		"services":[
+			{"name":"Example","secret":"` + testSecret + `","otp":{"label":"Example:alice","account":"alice","issuer":"Example","digits":8,"period":30,"algorithm":"SHA1","tokenType":"TOTP","source":"Link"}},
+			{"name":"Other","secret":"` + testSecret + `","otp":{"account":"carol","digits":6,"algorithm":"SHA1","counter":1,"tokenType":"HOTP"}},
+			{"name":"Steam","secret":"` + testSecret + `","otp":{"account":"bob","digits":5,"period":30,"algorithm":"SHA1","tokenType":"STEAM"}}
+		],
+		"schemaVersion":4
+	}`)
+
+	result, err := backup.ImportTwoFAS(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Entries) != 2 {
+		t.Fatalf("Expected 2 entries, Got: %d", len(result.Entries))
+	}
+
+	checkTOTP(t, result.Entries[0], "alice", "Example")
+	checkHOTP(t, result.Entries[1], "carol")
+	if result.Entries[1].Issuer != "Other" {
+		t.Errorf("Expected issuer to fall back to the service name, Got: %s", result.Entries[1].Issuer)
+	}
+	checkUnsupported(t, result, 2)
+
+	if _, err := backup.ImportTwoFAS([]byte(`{"services":[],"servicesEncrypted":"abc:def:ghi"}`)); !errors.Is(err, backup.ErrEncrypted) {
+		t.Errorf("Expected ErrEncrypted, Got: %v", err)
+	}
+}
+
+func TestImportFreeOTPPlus(t *testing.T) {
+	// "12345678901234567890" as signed bytes
+	secret := `[49,50,51,52,53,54,55,56,57,48,49,50,51,52,53,54,55,56,57,48]`
+	data := []byte(`{
+		"tokenOrder":["Example:alice","carol"],
+		"tokens":[
+			{"algo":"SHA1","counter":0,"digits":8,"issuerExt":"Example","issuerInt":"Example","label":"alice","period":30,"secret":` + secret + `,"type":"TOTP"},
+			{"algo":"MD5","counter":0,"digits":6,"issuerExt":"","label":"dave","period":30,"secret":` + secret + `,"type":"TOTP"},
+			{"algo":"SHA1","counter":1,"digits":6,"issuerExt":"","label":"carol","period":30,"secret":` + secret + `,"type":"HOTP"}
+		]
+	}`)
+
+	result, err := backup.ImportFreeOTPPlus(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Entries) != 2 {
+		t.Fatalf("Expected 2 entries, Got: %d", len(result.Entries))
+	}
+
+	checkTOTP(t, result.Entries[0], "alice", "Example")
+	checkHOTP(t, result.Entries[1], "carol")
+
+	if len(result.Errors) != 1 || !errors.Is(result.Errors[0], backup.ErrUnsupportedAlgorithm) {
+		t.Errorf("Expected an unsupported algorithm error, Got: %v", result.Errors)
+	}
+}
+
+func TestEntryKey(t *testing.T) {
+	result, err := backup.ImportAndOTP([]byte(`[{"secret":"` + testSecret + `","issuer":"Example","label":"alice","digits":6,"type":"TOTP","algorithm":"SHA256","period":60}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := result.Entries[0].Key()
+	if key.Type != "totp" || key.Label != "alice" || key.Issuer != "Example" || key.HashType != basicOTP.SHA256 || key.Period != 60 {
+		t.Errorf("Unexpected key: %+v", key)
+	}
+}
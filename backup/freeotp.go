@@ -0,0 +1,46 @@
+package backup
+
+import (
+	"encoding/json"
+)
+
+// freeOTPPlusBackup is a FreeOTP+ JSON backup.
+type freeOTPPlusBackup struct {
+	Tokens []freeOTPPlusToken `json:"tokens"`
+}
+
+// freeOTPPlusToken is an account in a FreeOTP+ backup.
+// The secret is stored as an array of signed bytes.
+type freeOTPPlusToken struct {
+	Algo      string `json:"algo"`
+	Counter   int    `json:"counter"`
+	Digits    int    `json:"digits"`
+	IssuerExt string `json:"issuerExt"`
+	Label     string `json:"label"`
+	Period    int    `json:"period"`
+	Secret    []int  `json:"secret"`
+	Type      string `json:"type"`
+}
+
+// ImportFreeOTPPlus reads a FreeOTP+ JSON backup.
+func ImportFreeOTPPlus(data []byte) (Import, error) {
+	var backup freeOTPPlusBackup
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return Import{}, err
+	}
+
+	var result Import
+	for index, token := range backup.Tokens {
+		key, err := newKey(token.Type, token.Label, token.IssuerExt, token.Algo, token.Digits, token.Period, token.Counter)
+
+		key.Secret = make([]byte, len(token.Secret))
+		for i, b := range token.Secret {
+			if b < -128 || b > 255 {
+				err = ErrInvalidSecret
+			}
+			key.Secret[i] = byte(b)
+		}
+		result.add(index, key, err)
+	}
+	return result, nil
+}
@@ -0,0 +1,64 @@
+package backup
+
+import (
+	"encoding/json"
+)
+
+// twoFASBackup is a 2FAS JSON backup.
+type twoFASBackup struct {
+	Services          []twoFASService `json:"services"`
+	ServicesEncrypted string          `json:"servicesEncrypted"`
+}
+
+// twoFASService is an account in a 2FAS backup.
+type twoFASService struct {
+	Name   string `json:"name"`
+	Secret string `json:"secret"`
+	OTP    struct {
+		Label     string `json:"label"`
+		Account   string `json:"account"`
+		Issuer    string `json:"issuer"`
+		Digits    int    `json:"digits"`
+		Period    int    `json:"period"`
+		Algorithm string `json:"algorithm"`
+		Counter   int    `json:"counter"`
+		TokenType string `json:"tokenType"`
+	} `json:"otp"`
+}
+
+// ImportTwoFAS reads an unencrypted 2FAS JSON backup.
+// Password protected backups return ErrEncrypted.
+func ImportTwoFAS(data []byte) (Import, error) {
+	var backup twoFASBackup
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return Import{}, err
+	}
+
+	if backup.ServicesEncrypted != "" && len(backup.Services) == 0 {
+		return Import{}, ErrEncrypted
+	}
+
+	var result Import
+	for index, s := range backup.Services {
+		label := s.OTP.Account
+		if label == "" {
+			label = s.OTP.Label
+		}
+		issuer := s.OTP.Issuer
+		if issuer == "" {
+			issuer = s.Name
+		}
+
+		tokenType := s.OTP.TokenType
+		if tokenType == "" {
+			tokenType = "totp"
+		}
+
+		key, err := newKey(tokenType, label, issuer, s.OTP.Algorithm, s.OTP.Digits, s.OTP.Period, s.OTP.Counter)
+		if err == nil {
+			key.Secret, err = decodeSecret(s.Secret)
+		}
+		result.add(index, key, err)
+	}
+	return result, nil
+}
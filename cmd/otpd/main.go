@@ -0,0 +1,54 @@
+// Command otpd is a standalone OTP verification service.
+//
+// It exposes enroll, verify, resync and delete endpoints over JSON/HTTP,
+// keeps credentials in a file encrypted with AES-256-GCM and locks users
+// out after repeated failed verifications.
+//
+// The 32 byte store encryption key is read hex encoded from OTPD_KEY.
+//
+//	OTPD_KEY=$(openssl rand -hex 32) otpd -addr 127.0.0.1:8080 -store otpd.json
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:8080", "address to listen on")
+	storePath := flag.String("store", "otpd.json", "path of the encrypted credential store")
+	issuer := flag.String("issuer", "otpd", "issuer reported in enrollment URIs")
+	window := flag.Int("window", 1, "TOTP time steps accepted on either side of the current one")
+	syncLimit := flag.Int("sync-limit", 10, "HOTP codes to look ahead during verification")
+	resyncLimit := flag.Int("resync-limit", 100, "codes or time steps searched during resync")
+	maxFailures := flag.Int("max-failures", 5, "consecutive failures before a user is locked out")
+	lockout := flag.Duration("lockout", 5*time.Minute, "duration of a lockout")
+	flag.Parse()
+
+	key, err := hex.DecodeString(os.Getenv("OTPD_KEY"))
+	if err != nil || len(key) != 32 {
+		log.Fatal("otpd: OTPD_KEY must hold a hex encoded 32 byte key")
+	}
+
+	store, err := newFileStore(*storePath, key)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	s := &server{
+		store:       store,
+		throttle:    newThrottle(*maxFailures, *lockout),
+		issuer:      *issuer,
+		window:      *window,
+		syncLimit:   *syncLimit,
+		resyncLimit: *resyncLimit,
+		now:         time.Now,
+	}
+
+	log.Printf("otpd: listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, s.handler()))
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sebastian-mora/basicOTP"
+)
+
+// server implements the otpd JSON API.
+type server struct {
+	mu          sync.Mutex // mu serializes the read-modify-write of records.
+	store       *fileStore
+	throttle    *throttle
+	issuer      string           // issuer is reported in enrollment URIs.
+	window      int              // window is the TOTP validation window in time steps.
+	syncLimit   int              // syncLimit is the HOTP look-ahead during verification.
+	resyncLimit int              // resyncLimit is how far resync searches for two consecutive codes.
+	now         func() time.Time // now returns the current time.
+}
+
+type enrollRequest struct {
+	User      string            `json:"user"`
+	Type      string            `json:"type"`
+	Label     string            `json:"label"`
+	Algorithm basicOTP.HashType `json:"algorithm"`
+	Digits    int               `json:"digits"`
+}
+
+type enrollResponse struct {
+	User   string `json:"user"`
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type verifyRequest struct {
+	User string `json:"user"`
+	Code string `json:"code"`
+}
+
+type verifyResponse struct {
+	Valid   bool   `json:"valid"`
+	Counter int    `json:"counter"`
+	Offset  int    `json:"offset"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+type resyncRequest struct {
+	User  string    `json:"user"`
+	Codes [2]string `json:"codes"`
+}
+
+type resyncResponse struct {
+	Synced bool `json:"synced"`
+}
+
+type deleteRequest struct {
+	User string `json:"user"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// apiError is an error reported to the client with a status code.
+type apiError struct {
+	status  int
+	message string
+}
+
+func (e *apiError) Error() string {
+	return e.message
+}
+
+var (
+	errBadRequest = &apiError{http.StatusBadRequest, "invalid request"}
+	errUnknown    = &apiError{http.StatusNotFound, "unknown user"}
+	errExists     = &apiError{http.StatusConflict, "user is already enrolled"}
+	errLockedOut  = &apiError{http.StatusTooManyRequests, "too many failed attempts"}
+)
+
+// handler returns the HTTP routes of the API.
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/v1/enroll", jsonHandler(s.enroll))
+	mux.Handle("/v1/verify", jsonHandler(s.verify))
+	mux.Handle("/v1/resync", jsonHandler(s.resync))
+	mux.Handle("/v1/delete", jsonHandler(s.delete))
+	return mux
+}
+
+// jsonHandler decodes a POST body into Req and encodes the result of fn.
+func jsonHandler[Req any, Resp any](fn func(Req) (Resp, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(errorResponse{Error: "method not allowed"})
+			return
+		}
+
+		var request Req
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&request); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(errorResponse{Error: errBadRequest.message})
+			return
+		}
+
+		response, err := fn(request)
+		if err != nil {
+			var apiErr *apiError
+			if !errors.As(err, &apiErr) {
+				apiErr = &apiError{http.StatusInternalServerError, "internal error"}
+			}
+			w.WriteHeader(apiErr.status)
+			json.NewEncoder(w).Encode(errorResponse{Error: apiErr.message})
+			return
+		}
+		json.NewEncoder(w).Encode(response)
+	})
+}
+
+// enroll creates a credential with a random 160 bit secret.
+func (s *server) enroll(request enrollRequest) (enrollResponse, error) {
+	if request.User == "" {
+		return enrollResponse{}, errBadRequest
+	}
+	if request.Type == "" {
+		request.Type = "totp"
+	}
+	if request.Label == "" {
+		request.Label = request.User
+	}
+	if request.Digits != 0 && (request.Digits < 6 || request.Digits > 8) {
+		return enrollResponse{}, errBadRequest
+	}
+	if _, ok := basicOTP.LookupHash(request.Algorithm); request.Algorithm != "" && !ok {
+		return enrollResponse{}, errBadRequest
+	}
+
+	secret := make([]byte, 20)
+	if _, err := rand.Read(secret); err != nil {
+		return enrollResponse{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.store.get(request.User); err == nil {
+		return enrollResponse{}, errExists
+	} else if !errors.Is(err, errNotFound) {
+		return enrollResponse{}, err
+	}
+
+	var key basicOTP.Key
+	switch request.Type {
+	case "totp":
+		key = basicOTP.NewTOTP(basicOTP.TOTPConfig{
+			CodeLength: request.Digits,
+			HashType:   request.Algorithm,
+			Secret:     secret,
+		}).Key(request.Label, s.issuer)
+	case "hotp":
+		key = basicOTP.NewHTOP(basicOTP.HOTPConfig{
+			CodeLength: request.Digits,
+			HashType:   request.Algorithm,
+			Secret:     secret,
+		}).Key(request.Label, s.issuer)
+	default:
+		return enrollResponse{}, errBadRequest
+	}
+
+	if err := s.store.put(request.User, record{Key: key}); err != nil {
+		return enrollResponse{}, err
+	}
+
+	return enrollResponse{
+		User:   request.User,
+		Secret: base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(secret),
+		URI:    keyURI(key),
+	}, nil
+}
+
+// verify validates a code, advancing the stored counter or replay state on success.
+func (s *server) verify(request verifyRequest) (verifyResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if !s.throttle.allowed(request.User, now) {
+		return verifyResponse{}, errLockedOut
+	}
+
+	rec, err := s.load(request.User)
+	if errors.Is(err, errUnknown) {
+		// Answer and throttle like a wrong code of a default TOTP, so the
+		// users that exist can not be probed.
+		s.throttle.failure(request.User, now)
+		return verifyResponse{Counter: int(now.Unix()) / 30, Reason: basicOTP.ReasonInvalidCode.String()}, nil
+	}
+	if err != nil {
+		return verifyResponse{}, err
+	}
+
+	var result basicOTP.ValidationResult
+	var validationErr error
+	switch rec.Key.Type {
+	case "totp":
+		totp := s.totp(rec)
+		result, validationErr = totp.ValidateDetailedAt(now.Unix(), request.Code)
+		state := totp.State()
+		rec.State = &state
+	default:
+		hotp := s.hotp(rec)
+		result, validationErr = hotp.ValidateDetailed(request.Code)
+		rec.Key.Counter = hotp.Counter
+	}
+
+	if validationErr != nil {
+		s.throttle.failure(request.User, now)
+		return verifyResponse{Counter: result.Counter, Reason: result.Reason.String()}, nil
+	}
+
+	s.throttle.reset(request.User)
+	if err := s.store.put(request.User, rec); err != nil {
+		return verifyResponse{}, err
+	}
+	return verifyResponse{Valid: true, Counter: result.Counter, Offset: result.Offset}, nil
+}
+
+// resync searches for two consecutive codes within resyncLimit of the
+// expected counter or time step and moves the stored state to them.
+func (s *server) resync(request resyncRequest) (resyncResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if !s.throttle.allowed(request.User, now) {
+		return resyncResponse{}, errLockedOut
+	}
+
+	rec, err := s.load(request.User)
+	if errors.Is(err, errUnknown) {
+		s.throttle.failure(request.User, now)
+		return resyncResponse{}, nil
+	}
+	if err != nil {
+		return resyncResponse{}, err
+	}
+
+	otp := basicOTP.NewOTP(rec.Key.Secret, rec.Key.HashType, rec.Key.CodeLength)
+	matches := func(counter int) bool {
+		first := subtle.ConstantTimeCompare([]byte(otp.Generate(counter)), []byte(request.Codes[0]))
+		second := subtle.ConstantTimeCompare([]byte(otp.Generate(counter+1)), []byte(request.Codes[1]))
+		return first&second == 1
+	}
+
+	synced := false
+	switch rec.Key.Type {
+	case "totp":
+		step := int(now.Unix()) / rec.Key.Period
+		lastStep := -1
+		if rec.State != nil {
+			lastStep = rec.State.LastStep
+		}
+		for offset := -s.resyncLimit; offset <= s.resyncLimit && !synced; offset++ {
+			// Time steps that were already accepted can not be used again,
+			// so LastStep only moves forward.
+			if step+offset <= lastStep || !matches(step+offset) {
+				continue
+			}
+			rec.State = &basicOTP.TOTPState{Drift: offset + 1, LastStep: step + offset + 1}
+			synced = true
+		}
+	default:
+		for counter := rec.Key.Counter; counter < rec.Key.Counter+s.resyncLimit && !synced; counter++ {
+			if matches(counter) {
+				rec.Key.Counter = counter + 2
+				synced = true
+			}
+		}
+	}
+
+	if !synced {
+		s.throttle.failure(request.User, now)
+		return resyncResponse{}, nil
+	}
+
+	s.throttle.reset(request.User)
+	return resyncResponse{Synced: true}, s.store.put(request.User, rec)
+}
+
+// delete removes the credential of a user.
+func (s *server) delete(request deleteRequest) (struct{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.store.delete(request.User)
+	if errors.Is(err, errNotFound) {
+		return struct{}{}, errUnknown
+	}
+	s.throttle.reset(request.User)
+	return struct{}{}, err
+}
+
+// load reads the record of user, mapping a missing record to errUnknown.
+func (s *server) load(user string) (record, error) {
+	rec, err := s.store.get(user)
+	if errors.Is(err, errNotFound) {
+		return record{}, errUnknown
+	}
+	return rec, err
+}
+
+// totp creates the TOTP generator of a record with its saved state.
+func (s *server) totp(rec record) *basicOTP.TOTP {
+	totp := basicOTP.NewTOTP(basicOTP.TOTPConfig{
+		TimeInterval: rec.Key.Period,
+		CodeLength:   rec.Key.CodeLength,
+		HashType:     rec.Key.HashType,
+		Secret:       rec.Key.Secret,
+		Window:       s.window,
+	})
+	if rec.State != nil {
+		totp.SetState(*rec.State)
+	}
+	return totp
+}
+
+// hotp creates the HOTP generator of a record.
+func (s *server) hotp(rec record) *basicOTP.HTOP {
+	return basicOTP.NewHTOP(basicOTP.HOTPConfig{
+		CodeLength:           rec.Key.CodeLength,
+		HashType:             rec.Key.HashType,
+		Secret:               rec.Key.Secret,
+		Counter:              rec.Key.Counter,
+		SynchronizationLimit: s.syncLimit,
+	})
+}
+
+// keyURI returns the Key URI used to enroll an authenticator app.
+func keyURI(key basicOTP.Key) string {
+	if key.Type == "hotp" {
+		return key.HOTP().URI(key.Label, key.Issuer)
+	}
+	return key.TOTP().URI(key.Label, key.Issuer)
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sebastian-mora/basicOTP"
+)
+
+// newTestServer starts otpd on a temporary store with the clock fixed at now.
+func newTestServer(t *testing.T, now *time.Time) (*httptest.Server, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "otpd.json")
+	store, err := newFileStore(path, bytes.Repeat([]byte{0x42}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &server{
+		store:       store,
+		throttle:    newThrottle(3, time.Minute),
+		issuer:      "Example",
+		window:      1,
+		syncLimit:   10,
+		resyncLimit: 100,
+		now:         func() time.Time { return *now },
+	}
+
+	ts := httptest.NewServer(s.handler())
+	t.Cleanup(ts.Close)
+	return ts, path
+}
+
+// post sends a JSON request and decodes the JSON response into response.
+func post(t *testing.T, ts *httptest.Server, path string, request any, response any) int {
+	t.Helper()
+	body, _ := json.Marshal(request)
+	resp, err := http.Post(ts.URL+path, "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if response != nil {
+		if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// enrollClient enrolls user and returns the client side generator key.
+func enrollClient(t *testing.T, ts *httptest.Server, user string, otpType string) basicOTP.Key {
+	t.Helper()
+	var enrolled enrollResponse
+	if status := post(t, ts, "/v1/enroll", enrollRequest{User: user, Type: otpType}, &enrolled); status != http.StatusOK {
+		t.Fatalf("Enroll failed with status %d", status)
+	}
+
+	key, err := basicOTP.ParseURI(enrolled.URI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestOTPDTOTPFlow(t *testing.T) {
+	now := time.Unix(1706984502, 0)
+	ts, path := newTestServer(t, &now)
+
+	key := enrollClient(t, ts, "alice", "totp")
+	if key.Issuer != "Example" || key.Label != "alice" {
+		t.Errorf("Unexpected enrollment key: %+v", key)
+	}
+
+	if status := post(t, ts, "/v1/enroll", enrollRequest{User: "alice"}, nil); status != http.StatusConflict {
+		t.Errorf("Expected 409 for a second enrollment, Got: %d", status)
+	}
+
+	// The secret is not stored in plaintext
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), `"secret"`) || strings.Contains(string(data), `"algorithm"`) {
+		t.Error("Store contains plaintext records")
+	}
+
+	client := key.TOTP()
+	var result verifyResponse
+	post(t, ts, "/v1/verify", verifyRequest{User: "alice", Code: client.GenerateAt(now.Unix())}, &result)
+	if !result.Valid {
+		t.Errorf("Expected a valid code, Got: %+v", result)
+	}
+
+	// Replay state is persisted between requests
+	post(t, ts, "/v1/verify", verifyRequest{User: "alice", Code: client.GenerateAt(now.Unix())}, &result)
+	if result.Valid || result.Reason != basicOTP.ReasonReplayedCode.String() {
+		t.Errorf("Expected a replayed code, Got: %+v", result)
+	}
+
+	// The client clock runs 10 minutes fast, resync with two consecutive codes
+	ahead := now.Unix() + 600
+	codes := [2]string{client.GenerateAt(ahead), client.GenerateAt(ahead + 30)}
+	var synced resyncResponse
+	post(t, ts, "/v1/resync", resyncRequest{User: "alice", Codes: codes}, &synced)
+	if !synced.Synced {
+		t.Fatal("Resync failed")
+	}
+
+	now = now.Add(time.Minute)
+	post(t, ts, "/v1/verify", verifyRequest{User: "alice", Code: client.GenerateAt(now.Unix() + 600)}, &result)
+	if !result.Valid || result.Offset != 20 {
+		t.Errorf("Expected a valid code with offset 20 after resync, Got: %+v", result)
+	}
+
+	if status := post(t, ts, "/v1/delete", deleteRequest{User: "alice"}, nil); status != http.StatusOK {
+		t.Errorf("Expected 200 deleting alice, Got: %d", status)
+	}
+	post(t, ts, "/v1/verify", verifyRequest{User: "alice", Code: "000000"}, &result)
+	if result.Valid || result.Reason != basicOTP.ReasonInvalidCode.String() {
+		t.Errorf("Expected an invalid code after delete, Got: %+v", result)
+	}
+}
+
+func TestOTPDTOTPResyncReplay(t *testing.T) {
+	now := time.Unix(1706984502, 0)
+	ts, _ := newTestServer(t, &now)
+
+	client := enrollClient(t, ts, "alice", "totp").TOTP()
+
+	// The codes of two consecutive time steps are accepted.
+	for _, ahead := range []int64{0, 30} {
+		var result verifyResponse
+		post(t, ts, "/v1/verify", verifyRequest{User: "alice", Code: client.GenerateAt(now.Unix() + ahead)}, &result)
+		if !result.Valid {
+			t.Fatalf("Expected a valid code, Got: %+v", result)
+		}
+	}
+
+	// Resync with the already accepted codes must not move the replay state back.
+	var synced resyncResponse
+	codes := [2]string{client.GenerateAt(now.Unix()), client.GenerateAt(now.Unix() + 30)}
+	post(t, ts, "/v1/resync", resyncRequest{User: "alice", Codes: codes}, &synced)
+	if synced.Synced {
+		t.Error("Resync accepted codes that were already used")
+	}
+
+	var result verifyResponse
+	post(t, ts, "/v1/verify", verifyRequest{User: "alice", Code: client.GenerateAt(now.Unix())}, &result)
+	if result.Valid {
+		t.Errorf("Expected a used code to be rejected after resync, Got: %+v", result)
+	}
+}
+
+func TestOTPDHOTPResync(t *testing.T) {
+	now := time.Unix(1706984502, 0)
+	ts, _ := newTestServer(t, &now)
+
+	key := enrollClient(t, ts, "carol", "hotp")
+	otp := basicOTP.NewOTP(key.Secret, key.HashType, key.CodeLength)
+
+	// Counter 50 is beyond the sync limit of 10
+	var result verifyResponse
+	post(t, ts, "/v1/verify", verifyRequest{User: "carol", Code: otp.Generate(50)}, &result)
+	if result.Valid {
+		t.Fatal("Code outside the sync limit was accepted")
+	}
+
+	var synced resyncResponse
+	post(t, ts, "/v1/resync", resyncRequest{User: "carol", Codes: [2]string{otp.Generate(50), otp.Generate(51)}}, &synced)
+	if !synced.Synced {
+		t.Fatal("Resync failed")
+	}
+
+	post(t, ts, "/v1/verify", verifyRequest{User: "carol", Code: otp.Generate(52)}, &result)
+	if !result.Valid || result.Counter != 52 {
+		t.Errorf("Expected counter 52 to be valid after resync, Got: %+v", result)
+	}
+}
+
+func TestOTPDThrottle(t *testing.T) {
+	now := time.Unix(1706984502, 0)
+	ts, _ := newTestServer(t, &now)
+
+	key := enrollClient(t, ts, "alice", "totp")
+
+	for i := 0; i < 3; i++ {
+		if status := post(t, ts, "/v1/verify", verifyRequest{User: "alice", Code: "000000"}, nil); status != http.StatusOK {
+			t.Fatalf("Expected 200, Got: %d", status)
+		}
+	}
+
+	// Locked out, even with the right code
+	code := key.TOTP().GenerateAt(now.Unix())
+	if status := post(t, ts, "/v1/verify", verifyRequest{User: "alice", Code: code}, nil); status != http.StatusTooManyRequests {
+		t.Errorf("Expected 429, Got: %d", status)
+	}
+
+	now = now.Add(2 * time.Minute)
+	code = key.TOTP().GenerateAt(now.Unix())
+	var result verifyResponse
+	post(t, ts, "/v1/verify", verifyRequest{User: "alice", Code: code}, &result)
+	if !result.Valid {
+		t.Errorf("Expected a valid code after the lockout expired, Got: %+v", result)
+	}
+}
+
+func TestOTPDBadRequests(t *testing.T) {
+	now := time.Unix(1706984502, 0)
+	ts, _ := newTestServer(t, &now)
+
+	resp, err := http.Get(ts.URL + "/v1/verify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405, Got: %d", resp.StatusCode)
+	}
+
+	resp, err = http.Post(ts.URL+"/v1/enroll", "application/json", strings.NewReader("{"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400, Got: %d", resp.StatusCode)
+	}
+
+	if status := post(t, ts, "/v1/enroll", enrollRequest{User: "bob", Type: "motp"}, nil); status != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unsupported type, Got: %d", status)
+	}
+	for _, request := range []enrollRequest{
+		{User: "bob", Digits: -1},
+		{User: "bob", Digits: 11},
+		{User: "bob", Algorithm: "MD5"},
+	} {
+		if status := post(t, ts, "/v1/enroll", request, nil); status != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %+v, Got: %d", request, status)
+		}
+	}
+	if status := post(t, ts, "/v1/enroll", enrollRequest{User: "bob", Digits: 8, Algorithm: basicOTP.SHA256}, nil); status != http.StatusOK {
+		t.Errorf("Expected 200 for 8 digits and SHA256, Got: %d", status)
+	}
+	if status := post(t, ts, "/v1/delete", deleteRequest{User: "nobody"}, nil); status != http.StatusNotFound {
+		t.Errorf("Expected 404, Got: %d", status)
+	}
+}
+
+func TestOTPDUnknownUser(t *testing.T) {
+	now := time.Unix(1706984502, 0)
+	ts, _ := newTestServer(t, &now)
+
+	// Unknown users are answered and throttled like wrong codes.
+	for i := 0; i < 3; i++ {
+		var result verifyResponse
+		if status := post(t, ts, "/v1/verify", verifyRequest{User: "nobody", Code: "000000"}, &result); status != http.StatusOK || result.Reason != basicOTP.ReasonInvalidCode.String() {
+			t.Fatalf("Expected an invalid code, Got: %d %+v", status, result)
+		}
+	}
+	if status := post(t, ts, "/v1/verify", verifyRequest{User: "nobody", Code: "000000"}, nil); status != http.StatusTooManyRequests {
+		t.Errorf("Expected 429, Got: %d", status)
+	}
+	if status := post(t, ts, "/v1/resync", resyncRequest{User: "nobody"}, nil); status != http.StatusTooManyRequests {
+		t.Errorf("Expected 429, Got: %d", status)
+	}
+}
+
+func TestThrottlePrunesExpiredUsers(t *testing.T) {
+	now := time.Unix(1706984502, 0)
+	throttle := newThrottle(3, time.Minute)
+
+	// Every request probes a new unknown user
+	for round := 0; round < 10; round++ {
+		for i := 0; i < 1000; i++ {
+			throttle.failure(fmt.Sprintf("probe-%d-%d", round, i), now)
+		}
+		now = now.Add(time.Minute)
+	}
+
+	if len(throttle.users) > 2000 {
+		t.Errorf("Expected expired users to be pruned, %d are tracked", len(throttle.users))
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/sebastian-mora/basicOTP"
+)
+
+var errNotFound = errors.New("otpd: user not found")
+
+// record is the persisted credential of a user.
+type record struct {
+	Key   basicOTP.Key        `json:"key"`
+	State *basicOTP.TOTPState `json:"totp_state,omitempty"`
+}
+
+// sealedRecord is a record encrypted with AES-GCM, the user id is used as
+// additional data so records can not be swapped between users.
+type sealedRecord struct {
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// fileStore keeps encrypted records in a single JSON file.
+// Every change rewrites the file atomically.
+type fileStore struct {
+	mu   sync.Mutex
+	path string
+	aead cipher.AEAD
+}
+
+// newFileStore opens the store at path, encrypting records with a 32 byte key.
+func newFileStore(path string, key []byte) (*fileStore, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &fileStore{path: path, aead: aead}, nil
+}
+
+// get returns the record of user.
+func (s *fileStore) get(user string) (record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records, err := s.load()
+	if err != nil {
+		return record{}, err
+	}
+
+	sealed, ok := records[user]
+	if !ok {
+		return record{}, errNotFound
+	}
+
+	plaintext, err := s.aead.Open(nil, sealed.Nonce, sealed.Ciphertext, []byte(user))
+	if err != nil {
+		return record{}, err
+	}
+
+	var r record
+	err = json.Unmarshal(plaintext, &r)
+	return r, err
+}
+
+// put stores the record of user, replacing any existing one.
+func (s *fileStore) put(user string, r record) error {
+	plaintext, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records, err := s.load()
+	if err != nil {
+		return err
+	}
+	records[user] = sealedRecord{Nonce: nonce, Ciphertext: s.aead.Seal(nil, nonce, plaintext, []byte(user))}
+	return s.save(records)
+}
+
+// delete removes the record of user.
+func (s *fileStore) delete(user string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records, err := s.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := records[user]; !ok {
+		return errNotFound
+	}
+	delete(records, user)
+	return s.save(records)
+}
+
+// load reads every sealed record, a missing file is an empty store.
+func (s *fileStore) load() (map[string]sealedRecord, error) {
+	records := map[string]sealedRecord{}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return records, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, &records)
+	return records, err
+}
+
+// save writes the records to a temporary file and renames it over the store.
+func (s *fileStore) save(records map[string]sealedRecord) error {
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".otpd-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
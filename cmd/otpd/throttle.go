@@ -0,0 +1,89 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// minThrottlePrune is the number of tracked users below which expired
+// entries are not pruned.
+const minThrottlePrune = 64
+
+// throttle locks a user out after too many consecutive failed verifications,
+// as recommended in RFC 4226 section 7.3. Failures are forgotten once the user
+// has not failed for the lockout duration, so entries for unknown or idle
+// users do not accumulate.
+type throttle struct {
+	mu          sync.Mutex
+	maxFailures int
+	lockout     time.Duration
+	users       map[string]*throttleState
+	nextPrune   int // nextPrune is the number of tracked users at which expired entries are pruned.
+}
+
+// throttleState tracks the failures of a single user.
+type throttleState struct {
+	failures    int
+	lockedUntil time.Time
+	expires     time.Time // expires is when the state can be forgotten.
+}
+
+// newThrottle creates a throttle allowing maxFailures consecutive failures
+// before locking the user for the lockout duration.
+func newThrottle(maxFailures int, lockout time.Duration) *throttle {
+	return &throttle{maxFailures: maxFailures, lockout: lockout, users: map[string]*throttleState{}, nextPrune: minThrottlePrune}
+}
+
+// allowed reports whether user may attempt a verification at now.
+func (t *throttle) allowed(user string, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state, ok := t.users[user]
+	return !ok || !now.Before(state.lockedUntil)
+}
+
+// failure records a failed verification, locking the user once the limit is reached.
+func (t *throttle) failure(user string, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state, ok := t.users[user]
+	if !ok || !now.Before(state.expires) {
+		state = &throttleState{}
+		t.users[user] = state
+		t.prune(now)
+	}
+
+	state.failures++
+	state.expires = now.Add(t.lockout)
+	if state.failures >= t.maxFailures {
+		state.failures = 0
+		state.lockedUntil = now.Add(t.lockout)
+	}
+}
+
+// reset clears the failures of user after a successful verification.
+func (t *throttle) reset(user string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.users, user)
+}
+
+// prune drops expired entries once the number of tracked users doubled since
+// the last pruning, keeping the cost amortized constant per failure.
+func (t *throttle) prune(now time.Time) {
+	if len(t.users) < t.nextPrune {
+		return
+	}
+
+	for user, state := range t.users {
+		if !now.Before(state.expires) {
+			delete(t.users, user)
+		}
+	}
+	t.nextPrune = 2 * len(t.users)
+	if t.nextPrune < minThrottlePrune {
+		t.nextPrune = minThrottlePrune
+	}
+}
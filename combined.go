@@ -0,0 +1,51 @@
+package basicOTP
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidCredentials is returned by VerifyPasswordAndCode for any rejected
+// input, it does not reveal whether the password or the code was wrong.
+var ErrInvalidCredentials = errors.New("basicOTP: invalid credentials")
+
+// Validator is implemented by *TOTP, *HTOP and *RotatingCredential.
+type Validator interface {
+	CodeLength() int
+	ValidateDetailed(code string) (ValidationResult, error)
+}
+
+// ContextValidator is a Validator that also validates with a context, whose
+// RequestInfo is reported to observers. It is implemented by *TOTP and *HTOP.
+type ContextValidator interface {
+	Validator
+	ValidateContext(ctx context.Context, code string) (ValidationResult, error)
+}
+
+// VerifyPasswordAndCode verifies input made of a password with the code appended,
+// such as "hunter2123456", for systems that only have a single password field.
+// The last CodeLength characters are validated by validator, including its window
+// and replay protection, the rest is checked by verifyPassword.
+//
+// Both halves are always checked so the response and timing do not reveal which
+// one failed, a correct code is consumed even if the password is wrong.
+// Rejections return ErrInvalidCredentials, errors of verifyPassword are wrapped.
+func VerifyPasswordAndCode(input string, validator Validator, verifyPassword func(password string) (bool, error)) error {
+	split := len(input) - validator.CodeLength()
+	if split < 0 {
+		split = 0
+	}
+	password, code := input[:split], input[split:]
+
+	passwordValid, err := verifyPassword(password)
+	if err != nil {
+		return fmt.Errorf("basicOTP: password verification failed: %w", err)
+	}
+
+	_, codeErr := validator.ValidateDetailed(code)
+	if !passwordValid || password == "" || codeErr != nil {
+		return ErrInvalidCredentials
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package basicOTP_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sebastian-mora/basicOTP"
+)
+
+func TestVerifyPasswordAndCode(t *testing.T) {
+	verifyPassword := func(password string) (bool, error) {
+		return password == "hunter2", nil
+	}
+
+	testCases := []struct {
+		name     string
+		input    string
+		expected error
+	}{
+		{"valid", "hunter2755224", nil},
+		{"replayed code", "hunter2755224", basicOTP.ErrInvalidCredentials},
+		{"wrong password", "hunter3287082", basicOTP.ErrInvalidCredentials},
+		{"wrong code", "hunter2000000", basicOTP.ErrInvalidCredentials},
+		{"code only", "359152", basicOTP.ErrInvalidCredentials},
+		{"too short", "1234", basicOTP.ErrInvalidCredentials},
+	}
+
+	// Counter 0 of RFC 4226 Appendix D is 755224
+	hotp := basicOTP.NewHTOP(basicOTP.HOTPConfig{Secret: []byte("12345678901234567890"), SynchronizationLimit: 5})
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := basicOTP.VerifyPasswordAndCode(tc.input, hotp, verifyPassword); err != tc.expected {
+				t.Errorf("Expected %v, Got: %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestVerifyPasswordAndCodeVerifierError(t *testing.T) {
+	totp := basicOTP.NewTOTP(basicOTP.TOTPConfig{Secret: []byte("12345678901234567890"), CodeLength: 8})
+	unavailable := errors.New("directory unavailable")
+
+	err := basicOTP.VerifyPasswordAndCode("hunter212345678", totp, func(string) (bool, error) {
+		return false, unavailable
+	})
+	if !errors.Is(err, unavailable) || errors.Is(err, basicOTP.ErrInvalidCredentials) {
+		t.Errorf("Expected the verifier error, Got: %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+package basicOTP
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrCredentialNotFound  = errors.New("basicOTP: credential not found")
+	ErrDuplicateCredential = errors.New("basicOTP: duplicate credential ID")
+)
+
+// Credential is a single authenticator of a user, such as a phone app or a hardware token.
+type Credential struct {
+	ID        string    // ID uniquely identifies the credential within its set.
+	Label     string    // Label is a name chosen by the user, such as "Work phone".
+	Validator Validator // Validator is the *TOTP or *HTOP of the credential, nil in the copies returned by a CredentialSet.
+	Enabled   bool      // Enabled reports whether codes of the credential are accepted.
+	LastUsed  time.Time // LastUsed is when a code of the credential was last accepted, zero if never.
+}
+
+// CredentialResult describes the outcome of validating a code against a CredentialSet.
+type CredentialResult struct {
+	ID string // ID is the credential that matched, empty if the code was rejected.
+	ValidationResult
+}
+
+// CredentialSet holds the credentials of a single user and validates codes
+// against all of them. It is safe for concurrent use.
+type CredentialSet struct {
+	mu          sync.Mutex
+	credentials []*Credential
+}
+
+// NewCredentialSet creates an empty CredentialSet.
+func NewCredentialSet() *CredentialSet {
+	return &CredentialSet{}
+}
+
+// Add adds an enabled credential. IDs must be unique within the set.
+func (s *CredentialSet) Add(id string, label string, validator Validator) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.find(id) != nil {
+		return ErrDuplicateCredential
+	}
+	s.credentials = append(s.credentials, &Credential{ID: id, Label: label, Validator: validator, Enabled: true})
+	return nil
+}
+
+// Remove removes a credential, reporting whether it was present.
+func (s *CredentialSet) Remove(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, c := range s.credentials {
+		if c.ID == id {
+			s.credentials = append(s.credentials[:i:i], s.credentials[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Get returns a copy of a credential. The copy has no Validator, its state is
+// only updated through Validate under the lock of the set.
+func (s *CredentialSet) Get(id string) (Credential, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := s.find(id)
+	if c == nil {
+		return Credential{}, false
+	}
+	return c.snapshot(), true
+}
+
+// Credentials returns copies of all credentials in the order they were added,
+// without their Validator like Get.
+func (s *CredentialSet) Credentials() []Credential {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	credentials := make([]Credential, len(s.credentials))
+	for i, c := range s.credentials {
+		credentials[i] = c.snapshot()
+	}
+	return credentials
+}
+
+// SetEnabled enables or disables a credential.
+func (s *CredentialSet) SetEnabled(id string, enabled bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := s.find(id)
+	if c == nil {
+		return ErrCredentialNotFound
+	}
+	c.Enabled = enabled
+	return nil
+}
+
+// Validate validates code against each enabled credential in turn and reports
+// the first one that accepted it. Only the matching credential's counter or
+// replay state is updated and its LastUsed time recorded.
+//
+// If no credential accepts the code the most specific reason is reported:
+// replayed if any credential had already used the code, invalid if the code
+// had the length of any credential, otherwise malformed.
+func (s *CredentialSet) Validate(code string) (CredentialResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reason, checked := ReasonMalformedCode, false
+	for _, c := range s.credentials {
+		if !c.Enabled {
+			continue
+		}
+		checked = true
+
+		result, err := c.Validator.ValidateDetailed(code)
+		if err == nil {
+			c.LastUsed = time.Now()
+			return CredentialResult{ID: c.ID, ValidationResult: result}, nil
+		}
+
+		if result.Reason == ReasonReplayedCode || result.Reason == ReasonInvalidCode && reason == ReasonMalformedCode {
+			reason = result.Reason
+		}
+	}
+
+	if !checked {
+		reason = ReasonInvalidCode
+	}
+	return CredentialResult{ValidationResult: ValidationResult{Reason: reason}}, &ValidationError{Reason: reason}
+}
+
+// find returns the credential with the given ID, nil if there is none.
+func (s *CredentialSet) find(id string) *Credential {
+	for _, c := range s.credentials {
+		if c.ID == id {
+			return c
+		}
+	}
+	return nil
+}
+
+// snapshot returns a copy of the credential without its Validator.
+func (c *Credential) snapshot() Credential {
+	copied := *c
+	copied.Validator = nil
+	return copied
+}
@@ -0,0 +1,119 @@
+package basicOTP_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sebastian-mora/basicOTP"
+)
+
+// newTestCredentialSet returns a set with a phone app, a hardware HOTP token
+// and a second phone, together with client generators for each.
+func newTestCredentialSet(t *testing.T) (*basicOTP.CredentialSet, *basicOTP.TOTP, *basicOTP.HTOP, *basicOTP.TOTP) {
+	set := basicOTP.NewCredentialSet()
+	phoneSecret, tokenSecret, backupSecret := []byte("12345678901234567890"), []byte("abcdefghijabcdefghij"), []byte("09876543210987654321")
+
+	for _, c := range []struct {
+		id, label string
+		validator basicOTP.Validator
+	}{
+		{"phone", "Phone", basicOTP.NewTOTP(basicOTP.TOTPConfig{Secret: phoneSecret, Window: 1})},
+		{"token", "Hardware token", basicOTP.NewHTOP(basicOTP.HOTPConfig{CodeLength: 6, Secret: tokenSecret, SynchronizationLimit: 10})},
+		{"backup", "Second phone", basicOTP.NewTOTP(basicOTP.TOTPConfig{Secret: backupSecret, Window: 1})},
+	} {
+		if err := set.Add(c.id, c.label, c.validator); err != nil {
+			t.Fatalf("Unexpected error adding %s: %v", c.id, err)
+		}
+	}
+
+	return set,
+		basicOTP.NewTOTP(basicOTP.TOTPConfig{Secret: phoneSecret}),
+		basicOTP.NewHTOP(basicOTP.HOTPConfig{CodeLength: 6, Secret: tokenSecret}),
+		basicOTP.NewTOTP(basicOTP.TOTPConfig{Secret: backupSecret})
+}
+
+func TestCredentialSetValidate(t *testing.T) {
+	set, phone, token, backup := newTestCredentialSet(t)
+
+	token.Generate() // skipped by the user
+	result, err := set.Validate(token.Generate())
+	if err != nil || result.ID != "token" || result.Counter != 1 {
+		t.Fatalf("Expected token to match counter 1, got %+v, %v", result, err)
+	}
+
+	result, err = set.Validate(backup.Generate())
+	if err != nil || result.ID != "backup" {
+		t.Fatalf("Expected backup phone to match, got %+v, %v", result, err)
+	}
+
+	if c, _ := set.Get("token"); c.LastUsed.IsZero() {
+		t.Error("Expected token LastUsed to be recorded")
+	}
+	if c, _ := set.Get("phone"); !c.LastUsed.IsZero() {
+		t.Error("Expected phone LastUsed to be unchanged")
+	}
+
+	// The phone has not been used, so its code is still accepted.
+	result, err = set.Validate(phone.Generate())
+	if err != nil || result.ID != "phone" {
+		t.Fatalf("Expected phone to match, got %+v, %v", result, err)
+	}
+}
+
+func TestCredentialSetRejections(t *testing.T) {
+	set, phone, _, _ := newTestCredentialSet(t)
+
+	code := phone.Generate()
+	if _, err := set.Validate(code); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	result, err := set.Validate(code)
+	if !errors.Is(err, basicOTP.ErrReplayedCode) || result.ID != "" || result.Valid {
+		t.Errorf("Expected replayed code, got %+v, %v", result, err)
+	}
+
+	if _, err := set.Validate("12345"); !errors.Is(err, basicOTP.ErrMalformedCode) {
+		t.Errorf("Expected malformed code, got %v", err)
+	}
+
+	if _, err := set.Validate(phone.GenerateAt(0)); !errors.Is(err, basicOTP.ErrInvalidCode) {
+		t.Errorf("Expected invalid code, got %v", err)
+	}
+}
+
+func TestCredentialSetManagement(t *testing.T) {
+	set, _, token, _ := newTestCredentialSet(t)
+
+	if err := set.Add("phone", "Duplicate", basicOTP.NewTOTP(basicOTP.TOTPConfig{Secret: []byte("12345678901234567890")})); !errors.Is(err, basicOTP.ErrDuplicateCredential) {
+		t.Errorf("Expected duplicate error, got %v", err)
+	}
+
+	if err := set.SetEnabled("token", false); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	code := token.Generate()
+	if _, err := set.Validate(code); !errors.Is(err, basicOTP.ErrInvalidCode) {
+		t.Errorf("Expected disabled credential to reject code, got %v", err)
+	}
+
+	set.SetEnabled("token", true)
+	if result, err := set.Validate(code); err != nil || result.ID != "token" {
+		t.Errorf("Expected enabled credential to accept code, got %+v, %v", result, err)
+	}
+
+	if err := set.SetEnabled("missing", true); !errors.Is(err, basicOTP.ErrCredentialNotFound) {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+
+	if !set.Remove("phone") || set.Remove("phone") {
+		t.Error("Expected phone to be removed once")
+	}
+	credentials := set.Credentials()
+	if len(credentials) != 2 || credentials[0].ID != "token" || credentials[1].Label != "Second phone" {
+		t.Errorf("Unexpected credentials %+v", credentials)
+	}
+	if c, _ := set.Get("token"); c.Validator != nil || credentials[0].Validator != nil {
+		t.Error("Expected copies not to expose the validator")
+	}
+}
@@ -0,0 +1,174 @@
+package basicOTP
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrEnrollmentExpired   = errors.New("basicOTP: enrollment expired")
+	ErrEnrollmentConfirmed = errors.New("basicOTP: enrollment already confirmed")
+)
+
+// EnrollmentConfig holds configuration parameters for a new credential awaiting confirmation.
+type EnrollmentConfig struct {
+	Type                 string        // Type is either "totp" or "hotp", defaults to "totp".
+	Label                string        // Label identifies the account, usually "issuer:account".
+	Issuer               string        // Issuer is the provider or service the account belongs to.
+	HashType             HashType      // HashType is the hash algorithm used for OTP generation.
+	CodeLength           int           // CodeLength is the length of the generated OTP code.
+	TimeInterval         int           // TimeInterval is the TOTP time period in seconds.
+	Window               int           // Window is the number of TOTP time steps accepted on either side, defaults to 1.
+	SynchronizationLimit int           // SynchronizationLimit is the number of HOTP counters searched for the first code, defaults to 10.
+	Deadline             time.Duration // Deadline is how long the user has to confirm, defaults to 10 minutes.
+}
+
+// PendingEnrollment is a credential that is not active until the user has
+// proven it was set up correctly: one valid TOTP code, or two consecutive
+// HOTP codes, before ExpiresAt. It can be serialized to JSON between requests.
+type PendingEnrollment struct {
+	Key                  Key       `json:"key"`                   // Key holds the secret and parameters of the credential.
+	ExpiresAt            time.Time `json:"expires_at"`            // ExpiresAt is the deadline for confirmation.
+	Window               int       `json:"window"`                // Window is the number of TOTP time steps accepted on either side.
+	SynchronizationLimit int       `json:"synchronization_limit"` // SynchronizationLimit is the number of HOTP counters searched for the first code.
+	Confirmations        int       `json:"confirmations"`         // Confirmations is the number of consecutive codes accepted.
+	State                TOTPState `json:"state"`                 // State is the TOTP validation state after the last accepted code.
+}
+
+// NewEnrollment creates a pending credential with a random 160 bit secret.
+// Show the user the URI, usually as a QR code, and pass the codes they enter to Confirm.
+func NewEnrollment(config EnrollmentConfig, now time.Time) (*PendingEnrollment, error) {
+	if config.Type == "" {
+		config.Type = "totp"
+	}
+	if config.Window == 0 {
+		config.Window = 1
+	}
+	if config.SynchronizationLimit == 0 {
+		config.SynchronizationLimit = 10
+	}
+	if config.Deadline == 0 {
+		config.Deadline = 10 * time.Minute
+	}
+
+	secret := make([]byte, 20)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	var key Key
+	switch config.Type {
+	case "totp":
+		key = NewTOTP(TOTPConfig{
+			TimeInterval: config.TimeInterval,
+			CodeLength:   config.CodeLength,
+			HashType:     config.HashType,
+			Secret:       secret,
+		}).Key(config.Label, config.Issuer)
+	case "hotp":
+		key = NewHTOP(HOTPConfig{
+			CodeLength: config.CodeLength,
+			HashType:   config.HashType,
+			Secret:     secret,
+		}).Key(config.Label, config.Issuer)
+	default:
+		return nil, fmt.Errorf("basicOTP: unsupported OTP type %q", config.Type)
+	}
+
+	return &PendingEnrollment{
+		Key:                  key,
+		ExpiresAt:            now.Add(config.Deadline),
+		Window:               config.Window,
+		SynchronizationLimit: config.SynchronizationLimit,
+		State:                TOTPState{LastStep: -1},
+	}, nil
+}
+
+// URI returns the Key URI to show the user.
+func (p *PendingEnrollment) URI() string {
+	if p.Key.Type == "hotp" {
+		return p.Key.HOTP().URI(p.Key.Label, p.Key.Issuer)
+	}
+	return p.Key.TOTP().URI(p.Key.Label, p.Key.Issuer)
+}
+
+// Confirm checks a code entered by the user at now and reports whether the
+// credential is now active. A TOTP credential is activated by one valid code.
+// A HOTP credential needs a second code for the counter directly after the first,
+// a wrong code starts over. Expired enrollments return ErrEnrollmentExpired and
+// must be discarded, rejected codes return a *ValidationError.
+func (p *PendingEnrollment) Confirm(code string, now time.Time) (bool, error) {
+	if p.Active() {
+		return true, ErrEnrollmentConfirmed
+	}
+	if !now.Before(p.ExpiresAt) {
+		return false, ErrEnrollmentExpired
+	}
+
+	if p.Key.Type != "hotp" {
+		totp := p.TOTP()
+		if _, err := totp.ValidateDetailedAt(now.Unix(), code); err != nil {
+			return false, err
+		}
+		p.State = totp.State()
+		p.Confirmations = 1
+		return true, nil
+	}
+
+	hotp := NewHTOP(HOTPConfig{
+		CodeLength:           p.Key.CodeLength,
+		HashType:             p.Key.HashType,
+		Secret:               p.Key.Secret,
+		Counter:              p.Key.Counter,
+		SynchronizationLimit: p.SynchronizationLimit,
+	})
+	result, err := hotp.ValidateDetailed(code)
+	if err != nil {
+		p.Confirmations = 0
+		return false, err
+	}
+
+	p.Key.Counter = result.Counter + 1
+	if p.Confirmations == 1 && result.Offset == 0 {
+		p.Confirmations = 2
+		return true, nil
+	}
+	p.Confirmations = 1
+	return false, nil
+}
+
+// Active reports whether the enrollment has been confirmed.
+func (p *PendingEnrollment) Active() bool {
+	if p.Key.Type == "hotp" {
+		return p.Confirmations >= 2
+	}
+	return p.Confirmations >= 1
+}
+
+// TOTP creates the TOTP generator of the credential, including the replay
+// and drift state of the confirmation code.
+func (p *PendingEnrollment) TOTP() *TOTP {
+	totp := NewTOTP(TOTPConfig{
+		TimeInterval: p.Key.Period,
+		CodeLength:   p.Key.CodeLength,
+		HashType:     p.Key.HashType,
+		Secret:       p.Key.Secret,
+		Window:       p.Window,
+	})
+	totp.SetState(p.State)
+	return totp
+}
+
+// HOTP creates the HOTP generator of the credential, with the counter after
+// the last confirmed code and the look-ahead used during enrollment.
+func (p *PendingEnrollment) HOTP() *HTOP {
+	return NewHTOP(HOTPConfig{
+		CodeLength:           p.Key.CodeLength,
+		HashType:             p.Key.HashType,
+		Secret:               p.Key.Secret,
+		Counter:              p.Key.Counter,
+		SynchronizationLimit: p.SynchronizationLimit,
+	})
+}
@@ -0,0 +1,119 @@
+package basicOTP_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sebastian-mora/basicOTP"
+)
+
+var enrolledAt = time.Unix(1706984520, 0)
+
+func TestEnrollmentTOTP(t *testing.T) {
+	pending, err := basicOTP.NewEnrollment(basicOTP.EnrollmentConfig{Label: "Example:alice", Issuer: "Example"}, enrolledAt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(pending.URI(), "otpauth://totp/Example:alice?secret=") || len(pending.Key.Secret) != 20 {
+		t.Fatalf("Unexpected enrollment %+v", pending)
+	}
+
+	// The user's app is the key from the scanned URI.
+	key, err := basicOTP.ParseURI(pending.URI())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	app := key.TOTP()
+
+	if _, err := pending.Confirm(app.GenerateAt(enrolledAt.Unix()-600), enrolledAt); !errors.Is(err, basicOTP.ErrInvalidCode) || pending.Active() {
+		t.Fatalf("Expected wrong code to be rejected, got %v", err)
+	}
+
+	code := app.GenerateAt(enrolledAt.Unix() + 60)
+	active, err := pending.Confirm(code, enrolledAt.Add(time.Minute))
+	if err != nil || !active || !pending.Active() {
+		t.Fatalf("Expected enrollment to be confirmed, got %v, %v", active, err)
+	}
+
+	// The confirmation code can not be used again to log in.
+	if pending.TOTP().ValidateAt(enrolledAt.Unix()+60, code) {
+		t.Error("Expected confirmation code to be rejected as replayed")
+	}
+	if _, err := pending.Confirm(code, enrolledAt.Add(time.Minute)); !errors.Is(err, basicOTP.ErrEnrollmentConfirmed) {
+		t.Errorf("Expected already confirmed error, got %v", err)
+	}
+}
+
+func TestEnrollmentHOTP(t *testing.T) {
+	pending, err := basicOTP.NewEnrollment(basicOTP.EnrollmentConfig{Type: "hotp", Label: "alice", CodeLength: 8}, enrolledAt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	token := basicOTP.NewHTOP(basicOTP.HOTPConfig{Secret: pending.Key.Secret, CodeLength: 8})
+	token.Generate() // pressed while unpacking
+
+	active, err := pending.Confirm(token.Generate(), enrolledAt)
+	if err != nil || active {
+		t.Fatalf("Expected first code to be accepted without activating, got %v, %v", active, err)
+	}
+
+	// A skipped code breaks the sequence, the next one counts as the first again.
+	token.Generate()
+	active, err = pending.Confirm(token.Generate(), enrolledAt)
+	if err != nil || active {
+		t.Fatalf("Expected non-consecutive code to restart confirmation, got %v, %v", active, err)
+	}
+
+	active, err = pending.Confirm(token.Generate(), enrolledAt)
+	if err != nil || !active {
+		t.Fatalf("Expected consecutive code to activate, got %v, %v", active, err)
+	}
+
+	hotp := pending.HOTP()
+	if hotp.Counter != 5 || !hotp.Validate(token.Generate()) {
+		t.Errorf("Expected credential to continue at counter 5, got %d", hotp.Counter)
+	}
+
+	// The look-ahead of the enrollment carries over.
+	token.Generate()
+	token.Generate()
+	if !hotp.Validate(token.Generate()) {
+		t.Error("Expected credential to re-synchronize after skipped codes")
+	}
+}
+
+func TestEnrollmentExpiryAndSerialization(t *testing.T) {
+	pending, err := basicOTP.NewEnrollment(basicOTP.EnrollmentConfig{Type: "hotp", Deadline: time.Minute}, enrolledAt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	token := basicOTP.NewHTOP(basicOTP.HOTPConfig{Secret: pending.Key.Secret})
+
+	if _, err := pending.Confirm(token.Generate(), enrolledAt); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Survives a page reload between the two codes.
+	data, err := json.Marshal(pending)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var restored basicOTP.PendingEnrollment
+	if err := json.Unmarshal(data, &restored); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if restored.Confirmations != 1 || restored.Key.Counter != 1 || !restored.ExpiresAt.Equal(pending.ExpiresAt) {
+		t.Fatalf("Unexpected restored enrollment %+v", restored)
+	}
+
+	if _, err := restored.Confirm(token.Generate(), enrolledAt.Add(time.Minute)); !errors.Is(err, basicOTP.ErrEnrollmentExpired) || restored.Active() {
+		t.Errorf("Expected expired enrollment, got %v", err)
+	}
+
+	if _, err := basicOTP.NewEnrollment(basicOTP.EnrollmentConfig{Type: "skey"}, enrolledAt); err == nil {
+		t.Error("Expected error for unsupported type")
+	}
+}
@@ -0,0 +1,72 @@
+package basicOTP
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+	"strings"
+	"sync"
+)
+
+var (
+	hashRegistryMu sync.RWMutex
+	hashRegistry   = map[HashType]func() hash.Hash{
+		SHA1:       sha1.New,
+		SHA224:     sha256.New224,
+		SHA256:     sha256.New,
+		SHA384:     sha512.New384,
+		SHA512:     sha512.New,
+		SHA512_224: sha512.New512_224,
+		SHA512_256: sha512.New512_256,
+	}
+)
+
+// RegisterHash makes a hash algorithm available under the given HashType,
+// replacing any previous registration. The standard library SHA-1 and SHA-2
+// family are registered by default.
+// The hash must produce at least 20 bytes to leave room for dynamic truncation.
+// Names are matched ignoring case when parsing URIs, so a name differing from
+// a registered one only in case is rejected.
+func RegisterHash(hashType HashType, hashFunc func() hash.Hash) {
+	if hashType == "" || hashFunc == nil {
+		panic("RegisterHash requires a name and a hash function")
+	}
+	if hashFunc().Size() < 20 {
+		panic("RegisterHash requires a hash of at least 160 bits")
+	}
+
+	hashRegistryMu.Lock()
+	defer hashRegistryMu.Unlock()
+	for registered := range hashRegistry {
+		if registered != hashType && strings.EqualFold(string(registered), string(hashType)) {
+			panic("RegisterHash name conflicts with " + string(registered))
+		}
+	}
+	hashRegistry[hashType] = hashFunc
+}
+
+// LookupHash returns the hash function registered for hashType.
+func LookupHash(hashType HashType) (func() hash.Hash, bool) {
+	hashRegistryMu.RLock()
+	defer hashRegistryMu.RUnlock()
+	hashFunc, ok := hashRegistry[hashType]
+	return hashFunc, ok
+}
+
+// parseHashType finds a registered HashType by name, ignoring case.
+// RegisterHash ensures at most one registered name matches.
+func parseHashType(name string) (HashType, bool) {
+	if _, ok := LookupHash(HashType(name)); ok {
+		return HashType(name), true
+	}
+
+	hashRegistryMu.RLock()
+	defer hashRegistryMu.RUnlock()
+	for hashType := range hashRegistry {
+		if strings.EqualFold(string(hashType), name) {
+			return hashType, true
+		}
+	}
+	return "", false
+}
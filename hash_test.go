@@ -0,0 +1,97 @@
+package basicOTP_test
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/sebastian-mora/basicOTP"
+)
+
+func TestBuiltinHashes(t *testing.T) {
+	hashes := []struct {
+		hashType basicOTP.HashType
+		size     int
+	}{
+		{basicOTP.SHA1, 20},
+		{basicOTP.SHA224, 28},
+		{basicOTP.SHA256, 32},
+		{basicOTP.SHA384, 48},
+		{basicOTP.SHA512, 64},
+		{basicOTP.SHA512_224, 28},
+		{basicOTP.SHA512_256, 32},
+	}
+
+	for _, tc := range hashes {
+		t.Run(string(tc.hashType), func(t *testing.T) {
+			hashFunc, ok := basicOTP.LookupHash(tc.hashType)
+			if !ok {
+				t.Fatalf("Hash %s is not registered", tc.hashType)
+			}
+			if hashFunc().Size() != tc.size {
+				t.Errorf("Expected hash size %d, Got: %d", tc.size, hashFunc().Size())
+			}
+
+			otp := basicOTP.NewOTP([]byte("test"), tc.hashType, 6)
+			if otp.HashType != tc.hashType {
+				t.Errorf("Expected hash type %s, Got: %s", tc.hashType, otp.HashType)
+			}
+		})
+	}
+}
+
+// registrations makes the registered names unique when the tests run more than once.
+var registrations = 0
+
+func TestRegisterHash(t *testing.T) {
+	registrations++
+	custom := basicOTP.HashType(fmt.Sprintf("CUSTOM-SHA256-%d", registrations))
+	if _, ok := basicOTP.LookupHash(custom); ok {
+		t.Fatal("Custom hash registered before RegisterHash")
+	}
+
+	basicOTP.RegisterHash(custom, sha256.New)
+
+	otp := basicOTP.NewOTP([]byte("test"), custom, 6)
+	if otp.HashType != custom {
+		t.Errorf("Expected hash type %s, Got: %s", custom, otp.HashType)
+	}
+
+	reference := basicOTP.NewOTP([]byte("test"), basicOTP.SHA256, 6)
+	if otp.Generate(42) != reference.Generate(42) {
+		t.Error("Registered hash did not produce the same code as SHA256")
+	}
+}
+
+func TestRegisterHashCaseConflict(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("The code did not panic")
+		}
+	}()
+
+	basicOTP.RegisterHash("sha256", sha256.New)
+}
+
+func TestHashTypeURIEscaping(t *testing.T) {
+	totp := basicOTP.NewTOTP(basicOTP.TOTPConfig{HashType: basicOTP.SHA512_224, Secret: []byte("test")})
+	uri := totp.URI("alice", "Example")
+	if !strings.Contains(uri, "&algorithm=SHA512%2F224&") {
+		t.Fatalf("Expected escaped algorithm, Got: %s", uri)
+	}
+	key, err := basicOTP.ParseURI(uri)
+	if err != nil || key.HashType != basicOTP.SHA512_224 {
+		t.Errorf("Expected %s to round trip, Got: %+v, %v", basicOTP.SHA512_224, key, err)
+	}
+}
+
+func TestRegisterHashPanic(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("The code did not panic")
+		}
+	}()
+
+	basicOTP.RegisterHash("", sha256.New)
+}
@@ -1,16 +1,24 @@
 package basicOTP
 
 import (
+	"context"
 	"encoding/base32"
 	"fmt"
 	"net/url"
+	"sync"
 )
 
 // HTOP represents a Sequence-based One-Time Password generator.
+// Its methods are safe for concurrent use, validations are serialized so a
+// code is accepted at most once, but Counter must not be accessed directly
+// while they run.
 type HTOP struct {
+	mu                   sync.Mutex // mu guards Counter across a validation.
 	otp                  OTP
 	Counter              int
 	synchronizationLimit int
+	id                   string
+	observer             Observer
 }
 
 // HOTPConfig holds configuration parameters for HOTP generation.
@@ -20,6 +28,8 @@ type HOTPConfig struct {
 	Secret               []byte   // Secret is the shared secret key used for OTP generation.
 	Counter              int      // Counter is the initial counter value for HOTP generation.
 	SynchronizationLimit int      // SynchronizationLimit sets the limit for synchronization in HOTP validation.
+	ID                   string   // ID identifies the credential in the events reported to Observer.
+	Observer             Observer // Observer is notified of generate and validate events, if set.
 
 	// SynchronizationLimit specifies the maximum number of steps to look ahead during OTP validation.
 	// If SynchronizationLimit is 0 or negative, no synchronization is performed, and the Counter value remains unchanged.
@@ -34,43 +44,115 @@ func NewHTOP(config HOTPConfig) *HTOP {
 		otp:                  NewOTP(config.Secret, config.HashType, config.CodeLength),
 		Counter:              config.Counter,
 		synchronizationLimit: config.SynchronizationLimit,
+		id:                   config.ID,
+		observer:             config.Observer,
 	}
 }
 
 // Generate returns a string representing a HOTP code.
 // generating a code increments the HOTP counter
 func (h *HTOP) Generate() string {
-	code := h.otp.Generate(h.Counter)
-	h.Counter = h.Counter + 1
-	return code
+	counter := h.next()
+	observe(context.Background(), h.observer, h.id, Event{Type: EventGenerate, Counter: counter})
+	return h.otp.Generate(counter)
+}
+
+// next returns the current counter and increments it.
+func (h *HTOP) next() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.Counter++
+	return h.Counter - 1
 }
 
 // Validate validates an input OTP code against the current counter value.
 // the function will attempt to look ahead for codes using
 // synchronizationLimit as the upper bound.
 func (h *HTOP) Validate(input string) bool {
+	result, _ := h.ValidateContext(context.Background(), input)
+	return result.Valid
+}
+
+// ValidateDetailed validates an input OTP code like Validate but reports
+// the matched counter, how far ahead of the current counter it was and why
+// the code was rejected. A rejected code returns a *ValidationError.
+func (h *HTOP) ValidateDetailed(input string) (ValidationResult, error) {
+	return h.ValidateContext(context.Background(), input)
+}
+
+// ValidateContext validates an input OTP code like ValidateDetailed. The
+// RequestInfo carried by ctx is added to the events sent to the observer.
+// If ctx is canceled before the code is matched, the counter is left
+// unchanged and ctx.Err() is returned.
+func (h *HTOP) ValidateContext(ctx context.Context, input string) (ValidationResult, error) {
+	return h.validate(ctx, input, h.otp.Generate)
+}
+
+// validate checks input against the counters in the synchronization window,
+// computing the code of a counter with generate, and notifies the observer.
+func (h *HTOP) validate(ctx context.Context, input string, generate func(counter int) string) (ValidationResult, error) {
+	h.mu.Lock()
+	result, err := h.match(ctx, input, generate)
+	h.mu.Unlock()
+
+	if !result.Valid && result.Reason == ReasonNone {
+		return result, err // canceled, the code was neither accepted nor rejected
+	}
+	observeValidation(ctx, h.observer, h.id, result)
+	if result.Valid && result.Offset > 0 {
+		observe(ctx, h.observer, h.id, Event{Type: EventCounterJump, Counter: result.Counter, Offset: result.Offset})
+	}
+	return result, err
+}
+
+// match finds the counter in the synchronization window whose code is input.
+// It must be called with mu held.
+func (h *HTOP) match(ctx context.Context, input string, generate func(counter int) string) (ValidationResult, error) {
+	if len(input) != h.otp.CodeLength {
+		return reject(h.Counter, ReasonMalformedCode)
+	}
+	if err := ctx.Err(); err != nil {
+		return ValidationResult{Counter: h.Counter}, err
+	}
 
 	// first check if input matches the current counter
-	if h.otp.Generate(h.Counter) == input {
+	if equalCodes(generate(h.Counter), input) {
 		h.Counter++
-		return true
+		return ValidationResult{Valid: true, Counter: h.Counter - 1, Advanced: true}, nil
 	}
 
 	// If we did not match, look ahead and sync if needed.
 	// i=1 as we have checked the first index already
 	for i := 1; i < h.synchronizationLimit; i++ {
-		if h.otp.Generate(h.Counter+i) == input {
+		if err := ctx.Err(); err != nil {
+			return ValidationResult{Counter: h.Counter}, err
+		}
+		if equalCodes(generate(h.Counter+i), input) {
 			h.Counter += i // Fast-forward counter to sync
-			return true
+			return ValidationResult{Valid: true, Counter: h.Counter, Offset: i, Advanced: true}, nil
 		}
 	}
 
-	return false
+	// The code for the previous counter has already been consumed.
+	if equalCodes(generate(h.Counter-1), input) {
+		return reject(h.Counter-1, ReasonReplayedCode)
+	}
+
+	return reject(h.Counter, ReasonInvalidCode)
+}
+
+// CodeLength returns the length of the codes generated by the HTOP.
+func (h *HTOP) CodeLength() int {
+	return h.otp.CodeLength
 }
 
 // URI generates the URI according to the Google Authenticator Key URI Format.
 // See: https://github.com/google/google-authenticator/wiki/Key-Uri-Format
 func (t *HTOP) URI(label string, issuer string) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
 	// Encode secret in Base32 without padding
 	encodedSecret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(t.otp.secret)
 
@@ -83,7 +165,23 @@ func (t *HTOP) URI(label string, issuer string) string {
 		encodedLabel,
 		encodedSecret,
 		encodedIssuer,
-		t.otp.HashType,
+		url.QueryEscape(string(t.otp.HashType)),
 		t.otp.CodeLength,
 		t.Counter)
 }
+
+// Key returns the parameters of the HTOP together with its label and issuer.
+func (h *HTOP) Key(label string, issuer string) Key {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return Key{
+		Type:       "hotp",
+		Label:      label,
+		Issuer:     issuer,
+		Secret:     h.otp.secret,
+		HashType:   h.otp.HashType,
+		CodeLength: h.otp.CodeLength,
+		Counter:    h.Counter,
+	}
+}
@@ -1,7 +1,10 @@
 package basicOTP_test
 
 import (
+	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
 
 	"github.com/sebastian-mora/basicOTP"
@@ -197,3 +200,65 @@ func TestHOPTURI(t *testing.T) {
 	}
 
 }
+
+func TestHOTPValidateDetailed(t *testing.T) {
+	config := basicOTP.HOTPConfig{
+		CodeLength:           6,
+		HashType:             basicOTP.SHA1,
+		Secret:               []byte("12345678901234567890"),
+		Counter:              0,
+		SynchronizationLimit: 10,
+	}
+	hotp := basicOTP.NewHTOP(config)
+
+	// Code for counter 0 matches without resync
+	result, err := hotp.ValidateDetailed("755224")
+	if err != nil || !result.Valid || result.Counter != 0 || result.Offset != 0 || !result.Advanced {
+		t.Errorf("Unexpected result for in sync code: %+v, %v", result, err)
+	}
+
+	// Reusing the same code is reported as a replay
+	result, err = hotp.ValidateDetailed("755224")
+	if !errors.Is(err, basicOTP.ErrReplayedCode) || result.Reason != basicOTP.ReasonReplayedCode {
+		t.Errorf("Expected replayed code, Got: %+v, %v", result, err)
+	}
+
+	// Code for counter 4 matches after resync
+	result, err = hotp.ValidateDetailed("338314")
+	if err != nil || result.Counter != 4 || result.Offset != 3 {
+		t.Errorf("Unexpected result for resynced code: %+v, %v", result, err)
+	}
+
+	result, err = hotp.ValidateDetailed("000000")
+	if !errors.Is(err, basicOTP.ErrInvalidCode) || result.Valid || result.Advanced {
+		t.Errorf("Expected invalid code, Got: %+v, %v", result, err)
+	}
+
+	var validationErr *basicOTP.ValidationError
+	_, err = hotp.ValidateDetailed("123")
+	if !errors.As(err, &validationErr) || validationErr.Reason != basicOTP.ReasonMalformedCode {
+		t.Errorf("Expected malformed code, Got: %v", err)
+	}
+}
+
+func TestHOTPConcurrentValidation(t *testing.T) {
+	hotp := basicOTP.NewHTOP(basicOTP.HOTPConfig{Secret: []byte("12345678901234567890"), SynchronizationLimit: 5})
+
+	var wg sync.WaitGroup
+	var accepted atomic.Int32
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if hotp.Validate("755224") { // counter 0 of the RFC 4226 test data
+				accepted.Add(1)
+			}
+			hotp.Key("carol", "")
+		}()
+	}
+	wg.Wait()
+
+	if accepted.Load() != 1 {
+		t.Errorf("Expected the code to be accepted once, got %d", accepted.Load())
+	}
+}
@@ -0,0 +1,155 @@
+package basicOTP
+
+import (
+	"encoding/base32"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// HybridOTP represents an event and time based One-Time Password generator.
+// Codes are derived from both a counter, as in HOTP, and the time step they
+// were generated in, so a code expires even if it was never used.
+type HybridOTP struct {
+	otp                  OTP // otp is the underlying OTP generator.
+	Counter              int // Counter is the next counter value.
+	TimeStep             int // TimeStep is the length of a time step in seconds.
+	Expiry               int // Expiry is how long in seconds a code remains valid after it was generated.
+	synchronizationLimit int
+}
+
+// HybridConfig holds configuration parameters for hybrid OTP generation.
+type HybridConfig struct {
+	CodeLength           int      // CodeLength is the length of the generated OTP code.
+	HashType             HashType // HashType is the hash algorithm used for OTP generation.
+	Secret               []byte   // Secret is the shared secret key used for OTP generation.
+	Counter              int      // Counter is the initial counter value.
+	SynchronizationLimit int      // SynchronizationLimit is the number of counter values accepted ahead of Counter.
+	TimeStep             int      // TimeStep is the length of a time step in seconds, defaults to 60.
+	Expiry               int      // Expiry is how long in seconds a code remains valid, defaults to 300.
+}
+
+// NewHybridOTP creates a new instance of HybridOTP based on the provided configuration.
+func NewHybridOTP(config HybridConfig) *HybridOTP {
+	if config.TimeStep == 0 {
+		config.TimeStep = 60
+	}
+	if config.Expiry == 0 {
+		config.Expiry = 300
+	}
+
+	return &HybridOTP{
+		otp:                  NewOTP(config.Secret, config.HashType, config.CodeLength),
+		Counter:              config.Counter,
+		TimeStep:             config.TimeStep,
+		Expiry:               config.Expiry,
+		synchronizationLimit: config.SynchronizationLimit,
+	}
+}
+
+// Generate returns a code for the current counter and time, incrementing the counter.
+func (h *HybridOTP) Generate() string {
+	return h.GenerateAt(time.Now().Unix())
+}
+
+// GenerateAt returns a code for the current counter issued at the given Unix timestamp,
+// incrementing the counter.
+func (h *HybridOTP) GenerateAt(unixTimestamp int64) string {
+	code := h.generate(h.Counter, h.timecode(unixTimestamp))
+	h.Counter++
+	return code
+}
+
+// Validate validates a code against the current time.
+func (h *HybridOTP) Validate(code string) bool {
+	result, _ := h.ValidateDetailed(code)
+	return result.Valid
+}
+
+// ValidateAt validates a code against the given Unix timestamp.
+func (h *HybridOTP) ValidateAt(unixTimestamp int64, code string) bool {
+	result, _ := h.ValidateDetailedAt(unixTimestamp, code)
+	return result.Valid
+}
+
+// ValidateDetailed validates a code against the current time and reports the matched counter.
+func (h *HybridOTP) ValidateDetailed(code string) (ValidationResult, error) {
+	return h.ValidateDetailedAt(time.Now().Unix(), code)
+}
+
+// ValidateDetailedAt validates a code against the given Unix timestamp.
+// The code must match a counter within the synchronization limit and a time step
+// at most Expiry seconds old, rounded to whole time steps. On success the counter
+// moves past the matched value so the code can not be used again.
+func (h *HybridOTP) ValidateDetailedAt(unixTimestamp int64, code string) (ValidationResult, error) {
+	if len(code) != h.otp.CodeLength {
+		return reject(h.Counter, ReasonMalformedCode)
+	}
+
+	step := h.timecode(unixTimestamp)
+	maxAge := h.Expiry / h.TimeStep
+	for i := 0; i < h.synchronizationLimit || i == 0; i++ {
+		for age := 0; age <= maxAge; age++ {
+			if equalCodes(h.generate(h.Counter+i, step-age), code) {
+				matched := h.Counter + i
+				h.Counter = matched + 1
+				return ValidationResult{Valid: true, Counter: matched, Offset: i, Advanced: true}, nil
+			}
+		}
+	}
+
+	return reject(h.Counter, ReasonInvalidCode)
+}
+
+// CodeLength returns the length of the codes generated by the HybridOTP.
+func (h *HybridOTP) CodeLength() int {
+	return h.otp.CodeLength
+}
+
+// URI generates the URI for the HybridOTP following the Google Authenticator
+// Key URI Format with the "hybrid" type, the period and expiry parameters.
+func (h *HybridOTP) URI(label string, issuer string) string {
+	// Encode secret in Base32 without padding
+	encodedSecret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(h.otp.secret)
+
+	// URL encode issuer and label
+	encodedIssuer := url.PathEscape(issuer)
+	encodedLabel := url.PathEscape(label)
+
+	// Construct the URI
+	return fmt.Sprintf("otpauth://hybrid/%s?secret=%s&issuer=%s&algorithm=%s&digits=%d&counter=%d&period=%d&expiry=%d",
+		encodedLabel,
+		encodedSecret,
+		encodedIssuer,
+		url.QueryEscape(string(h.otp.HashType)),
+		h.otp.CodeLength,
+		h.Counter,
+		h.TimeStep,
+		h.Expiry)
+}
+
+// Key returns the parameters of the HybridOTP together with its label and issuer.
+func (h *HybridOTP) Key(label string, issuer string) Key {
+	return Key{
+		Type:       "hybrid",
+		Label:      label,
+		Issuer:     issuer,
+		Secret:     h.otp.secret,
+		HashType:   h.otp.HashType,
+		CodeLength: h.otp.CodeLength,
+		Counter:    h.Counter,
+		Period:     h.TimeStep,
+		Expiry:     h.Expiry,
+	}
+}
+
+// generate computes the code for a counter and time step.
+// The HMAC message is the 8 byte counter followed by the 8 byte time step.
+func (h *HybridOTP) generate(counter int, step int) string {
+	return h.otp.GenerateMessage(append(CounterMessage(counter), CounterMessage(step)...))
+}
+
+// timecode calculates the time step of the provided Unix timestamp.
+func (h *HybridOTP) timecode(unixTimestamp int64) int {
+	return int(unixTimestamp) / h.TimeStep
+}
@@ -0,0 +1,125 @@
+package basicOTP_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sebastian-mora/basicOTP"
+)
+
+func newTestHybrid(counter int) *basicOTP.HybridOTP {
+	return basicOTP.NewHybridOTP(basicOTP.HybridConfig{
+		CodeLength:           6,
+		HashType:             basicOTP.SHA1,
+		Secret:               []byte("12345678901234567890"),
+		Counter:              counter,
+		SynchronizationLimit: 3,
+		TimeStep:             60,
+		Expiry:               300,
+	})
+}
+
+func TestHybridMessage(t *testing.T) {
+	issued := int64(1706984520)
+	code := newTestHybrid(7).GenerateAt(issued)
+
+	message := append(basicOTP.CounterMessage(7), basicOTP.CounterMessage(int(issued/60))...)
+	expected := basicOTP.NewOTP([]byte("12345678901234567890"), basicOTP.SHA1, 6).GenerateMessage(message)
+	if code != expected {
+		t.Errorf("Expected code %s, got %s", expected, code)
+	}
+
+	hotp := basicOTP.NewHTOP(basicOTP.HOTPConfig{CodeLength: 6, HashType: basicOTP.SHA1, Secret: []byte("12345678901234567890"), Counter: 7})
+	if code == hotp.Generate() {
+		t.Error("Expected hybrid code to differ from the HOTP code for the same counter")
+	}
+}
+
+func TestHybridValidate(t *testing.T) {
+	issued := int64(1706984520)
+	generator := newTestHybrid(0)
+	code := generator.GenerateAt(issued)
+
+	validator := newTestHybrid(0)
+	result, err := validator.ValidateDetailedAt(issued+120, code)
+	if err != nil || !result.Valid || result.Counter != 0 || result.Offset != 0 {
+		t.Fatalf("Expected code to be valid at counter 0, got %+v, %v", result, err)
+	}
+	if validator.Counter != 1 {
+		t.Errorf("Expected counter 1 after validation, got %d", validator.Counter)
+	}
+
+	if validator.ValidateAt(issued+120, code) {
+		t.Error("Expected used code to be rejected")
+	}
+}
+
+func TestHybridExpiry(t *testing.T) {
+	issued := int64(1706984520)
+	code := newTestHybrid(0).GenerateAt(issued)
+
+	if !newTestHybrid(0).ValidateAt(issued+299, code) {
+		t.Error("Expected code to be valid before it expired")
+	}
+
+	_, err := newTestHybrid(0).ValidateDetailedAt(issued+360, code)
+	if !errors.Is(err, basicOTP.ErrInvalidCode) {
+		t.Errorf("Expected expired code to be rejected as invalid, got %v", err)
+	}
+
+	if newTestHybrid(0).ValidateAt(issued-60, code) {
+		t.Error("Expected code from a future time step to be rejected")
+	}
+}
+
+func TestHybridSynchronization(t *testing.T) {
+	issued := int64(1706984520)
+	generator := newTestHybrid(0)
+	generator.GenerateAt(issued)
+	generator.GenerateAt(issued)
+	code := generator.GenerateAt(issued)
+
+	validator := newTestHybrid(0)
+	result, err := validator.ValidateDetailedAt(issued, code)
+	if err != nil || result.Counter != 2 || result.Offset != 2 {
+		t.Fatalf("Expected code to match counter 2, got %+v, %v", result, err)
+	}
+	if validator.Counter != 3 {
+		t.Errorf("Expected counter 3 after resynchronization, got %d", validator.Counter)
+	}
+
+	code = generator.GenerateAt(issued)
+	generator.Counter += 5
+	if newTestHybrid(0).ValidateAt(issued, generator.GenerateAt(issued)) {
+		t.Error("Expected code outside the synchronization limit to be rejected")
+	}
+
+	if _, err := validator.ValidateDetailedAt(issued, "12345"); !errors.Is(err, basicOTP.ErrMalformedCode) {
+		t.Errorf("Expected malformed code error, got %v", err)
+	}
+	if !validator.ValidateAt(issued, code) {
+		t.Error("Expected next code to be valid")
+	}
+}
+
+func TestHybridURIRoundTrip(t *testing.T) {
+	hybrid := newTestHybrid(5)
+	uri := hybrid.URI("Example:alice@example.com", "Example")
+
+	key, err := basicOTP.ParseURI(uri)
+	if err != nil {
+		t.Fatalf("Unexpected error parsing %s: %v", uri, err)
+	}
+	if key.Type != "hybrid" || key.Counter != 5 || key.Period != 60 || key.Expiry != 300 {
+		t.Fatalf("Unexpected key %+v", key)
+	}
+
+	issued := int64(1706984520)
+	if !key.Hybrid().ValidateAt(issued, hybrid.GenerateAt(issued)) {
+		t.Error("Expected key generator to validate code")
+	}
+
+	if _, err := basicOTP.ParseURI("otpauth://hybrid/x?secret=GEZDGNBV"); err == nil {
+		t.Error("Expected error for hybrid URI without counter")
+	}
+}
@@ -0,0 +1,148 @@
+// Package kdf implements the password based key derivation functions
+// PBKDF2 (RFC 8018) and scrypt (RFC 7914) so basicOTP stays free of
+// third-party dependencies.
+package kdf
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"math/bits"
+)
+
+// Bounds on the scrypt parameters to protect against files that request an
+// unreasonable amount of memory or time. Aegis uses N=2^15, r=8, p=1, 32 MiB.
+const (
+	MaxScryptN      = 1 << 20   // MaxScryptN bounds the cost parameter N.
+	MaxScryptMemory = 256 << 20 // MaxScryptMemory bounds the 128*N*r bytes of memory used.
+	MaxScryptP      = 16        // MaxScryptP bounds the parallelization parameter p.
+)
+
+// PBKDF2 derives a key of keyLen bytes from password and salt using
+// iter iterations of HMAC with the hash function h.
+func PBKDF2(password []byte, salt []byte, iter int, keyLen int, h func() hash.Hash) []byte {
+	prf := hmac.New(h, password)
+	hashLen := prf.Size()
+	numBlocks := (keyLen + hashLen - 1) / hashLen
+
+	var buf [4]byte
+	dk := make([]byte, 0, numBlocks*hashLen)
+	u := make([]byte, hashLen)
+	for block := 1; block <= numBlocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(buf[:], uint32(block))
+		prf.Write(buf[:])
+		dk = prf.Sum(dk)
+
+		t := dk[len(dk)-hashLen:]
+		copy(u, t)
+		for n := 2; n <= iter; n++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for i := range u {
+				t[i] ^= u[i]
+			}
+		}
+	}
+	return dk[:keyLen]
+}
+
+// Scrypt derives a key of keyLen bytes from password and salt.
+// n must be a power of two greater than 1 and no larger than MaxScryptN,
+// 128*n*r must not exceed MaxScryptMemory and p must not exceed MaxScryptP.
+func Scrypt(password []byte, salt []byte, n int, r int, p int, keyLen int) ([]byte, error) {
+	if n <= 1 || n&(n-1) != 0 || n > MaxScryptN {
+		return nil, errors.New("kdf: scrypt N must be a power of two between 2 and MaxScryptN")
+	}
+	if r <= 0 || p <= 0 || p > MaxScryptP || r > MaxScryptMemory/(128*n) {
+		return nil, errors.New("kdf: scrypt parameters are too large")
+	}
+
+	xy := make([]uint32, 64*r)
+	v := make([]uint32, 32*n*r)
+	b := PBKDF2(password, salt, 1, p*128*r, sha256.New)
+
+	for i := 0; i < p; i++ {
+		smix(b[i*128*r:], r, n, v, xy)
+	}
+
+	return PBKDF2(password, b, 1, keyLen, sha256.New), nil
+}
+
+// smix implements scryptROMix on a single 128*r byte block.
+func smix(b []byte, r int, n int, v []uint32, xy []uint32) {
+	x := xy[:32*r]
+	y := xy[32*r:]
+
+	for i := range x {
+		x[i] = binary.LittleEndian.Uint32(b[i*4:])
+	}
+
+	for i := 0; i < n; i++ {
+		copy(v[i*32*r:], x)
+		blockMix(x, y, r)
+	}
+
+	for i := 0; i < n; i++ {
+		j := int(x[(2*r-1)*16] & uint32(n-1))
+		for k := range x {
+			x[k] ^= v[j*32*r+k]
+		}
+		blockMix(x, y, r)
+	}
+
+	for i, w := range x {
+		binary.LittleEndian.PutUint32(b[i*4:], w)
+	}
+}
+
+// blockMix implements scryptBlockMix, y is scratch space of the same size as b.
+func blockMix(b []uint32, y []uint32, r int) {
+	var x [16]uint32
+	copy(x[:], b[(2*r-1)*16:])
+
+	for i := 0; i < 2*r; i++ {
+		for j := range x {
+			x[j] ^= b[i*16+j]
+		}
+		salsa208(&x)
+
+		// Even blocks go to the first half of the output, odd blocks to the second.
+		copy(y[(i/2+(i%2)*r)*16:], x[:])
+	}
+	copy(b, y)
+}
+
+// salsa208 applies the Salsa20/8 core to b.
+func salsa208(b *[16]uint32) {
+	x := *b
+	for i := 0; i < 8; i += 2 {
+		// column round
+		quarterRound(&x, 0, 4, 8, 12)
+		quarterRound(&x, 5, 9, 13, 1)
+		quarterRound(&x, 10, 14, 2, 6)
+		quarterRound(&x, 15, 3, 7, 11)
+
+		// row round
+		quarterRound(&x, 0, 1, 2, 3)
+		quarterRound(&x, 5, 6, 7, 4)
+		quarterRound(&x, 10, 11, 8, 9)
+		quarterRound(&x, 15, 12, 13, 14)
+	}
+
+	for i := range b {
+		b[i] += x[i]
+	}
+}
+
+// quarterRound applies the Salsa20 quarter round to the words a, b, c and d.
+func quarterRound(x *[16]uint32, a int, b int, c int, d int) {
+	x[b] ^= bits.RotateLeft32(x[a]+x[d], 7)
+	x[c] ^= bits.RotateLeft32(x[b]+x[a], 9)
+	x[d] ^= bits.RotateLeft32(x[c]+x[b], 13)
+	x[a] ^= bits.RotateLeft32(x[d]+x[c], 18)
+}
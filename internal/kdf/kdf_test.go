@@ -0,0 +1,84 @@
+package kdf_test
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/sebastian-mora/basicOTP/internal/kdf"
+)
+
+func TestPBKDF2(t *testing.T) {
+	testCases := []struct {
+		password string
+		salt     string
+		iter     int
+		keyLen   int
+		sha256   bool
+		expected string
+	}{
+		// RFC 6070 test vectors
+		{"password", "salt", 1, 20, false, "0c60c80f961f0e71f3a9b524af6012062fe037a6"},
+		{"password", "salt", 4096, 20, false, "4b007901b765489abead49d926f721d065a429c1"},
+		{"passwordPASSWORDpassword", "saltSALTsaltSALTsaltSALTsaltSALTsalt", 4096, 25, false, "3d2eec4fe41c849b80c8d83662c0e44a8b291a964cf2f07038"},
+
+		// PBKDF2-HMAC-SHA256 vector from RFC 7914 section 11
+		{"passwd", "salt", 1, 64, true, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"},
+	}
+
+	for _, tc := range testCases {
+		h := sha1.New
+		if tc.sha256 {
+			h = sha256.New
+		}
+
+		output := kdf.PBKDF2([]byte(tc.password), []byte(tc.salt), tc.iter, tc.keyLen, h)
+		if hex.EncodeToString(output) != tc.expected {
+			t.Errorf("Expected %s, Got: %x", tc.expected, output)
+		}
+	}
+}
+
+func TestScrypt(t *testing.T) {
+	// Test vectors from RFC 7914 section 12
+	testCases := []struct {
+		password string
+		salt     string
+		n, r, p  int
+		expected string
+	}{
+		{"", "", 16, 1, 1, "77d6576238657b203b19ca42c18a0497f16b4844e3074ae8dfdffa3fede21442fcd0069ded0948f8326a753a0fc81f17e8d3e0fb2e0d3628cf35e20c38d18906"},
+		{"password", "NaCl", 1024, 8, 16, "fdbabe1c9d3472007856e7190d01e9fe7c6ad7cbc8237830e77376634b3731622eaf30d92e22a3886ff109279d9830dac727afb94a83ee6d8360cbdfa2cc0640"},
+	}
+
+	for _, tc := range testCases {
+		output, err := kdf.Scrypt([]byte(tc.password), []byte(tc.salt), tc.n, tc.r, tc.p, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hex.EncodeToString(output) != tc.expected {
+			t.Errorf("Expected %s, Got: %x", tc.expected, output)
+		}
+	}
+}
+
+func TestScryptInvalidParameters(t *testing.T) {
+	for _, n := range []int{0, 1, 1000, kdf.MaxScryptN * 2} {
+		if _, err := kdf.Scrypt([]byte("password"), []byte("salt"), n, 8, 1, 32); err == nil {
+			t.Errorf("Expected an error for N=%d", n)
+		}
+	}
+
+	// Parameters a hostile file could request
+	for _, params := range [][3]int{
+		{kdf.MaxScryptN, 1024, 1}, // 128 GiB
+		{1 << 15, 8, 1 << 30},     // effectively forever
+		{1 << 15, 0, 1},
+		{1 << 15, 8, 0},
+	} {
+		if _, err := kdf.Scrypt([]byte("password"), []byte("salt"), params[0], params[1], params[2], 32); err == nil {
+			t.Errorf("Expected an error for N=%d, r=%d, p=%d", params[0], params[1], params[2])
+		}
+	}
+}
@@ -0,0 +1,125 @@
+// Package md4 implements the MD4 hash algorithm (RFC 1320), needed for
+// S/KEY md4 hash chains. MD4 is broken and must not be used for anything else.
+package md4
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+)
+
+// Size is the size of an MD4 checksum in bytes.
+const Size = 16
+
+// BlockSize is the block size of MD4 in bytes.
+const BlockSize = 64
+
+type digest struct {
+	s   [4]uint32
+	x   [BlockSize]byte
+	nx  int
+	len uint64
+}
+
+// New returns a new hash.Hash computing the MD4 checksum.
+func New() hash.Hash {
+	d := new(digest)
+	d.Reset()
+	return d
+}
+
+// Sum returns the MD4 checksum of data.
+func Sum(data []byte) [Size]byte {
+	d := new(digest)
+	d.Reset()
+	d.Write(data)
+	var sum [Size]byte
+	d.Sum(sum[:0])
+	return sum
+}
+
+func (d *digest) Reset() {
+	d.s = [4]uint32{0x67452301, 0xefcdab89, 0x98badcfe, 0x10325476}
+	d.nx = 0
+	d.len = 0
+}
+
+func (d *digest) Size() int { return Size }
+
+func (d *digest) BlockSize() int { return BlockSize }
+
+func (d *digest) Write(p []byte) (int, error) {
+	n := len(p)
+	d.len += uint64(n)
+	if d.nx > 0 {
+		copied := copy(d.x[d.nx:], p)
+		d.nx += copied
+		p = p[copied:]
+		if d.nx < BlockSize {
+			return n, nil
+		}
+		d.block(d.x[:])
+		d.nx = 0
+	}
+	for len(p) >= BlockSize {
+		d.block(p[:BlockSize])
+		p = p[BlockSize:]
+	}
+	d.nx = copy(d.x[:], p)
+	return n, nil
+}
+
+func (d *digest) Sum(in []byte) []byte {
+	// Work on a copy so the caller can keep writing.
+	c := *d
+
+	// Pad with a 1 bit, zeros up to 56 bytes mod 64 and the length in bits.
+	var padding [BlockSize + 8]byte
+	padding[0] = 0x80
+	padLen := (55-c.len%BlockSize)%BlockSize + 1
+	binary.LittleEndian.PutUint64(padding[padLen:], c.len<<3)
+	c.Write(padding[:padLen+8])
+
+	for _, s := range c.s {
+		in = binary.LittleEndian.AppendUint32(in, s)
+	}
+	return in
+}
+
+// block processes a single 64 byte block.
+func (d *digest) block(p []byte) {
+	var x [16]uint32
+	for i := range x {
+		x[i] = binary.LittleEndian.Uint32(p[i*4:])
+	}
+	a, b, c, e := d.s[0], d.s[1], d.s[2], d.s[3]
+
+	// Round 1
+	for _, i := range []int{0, 4, 8, 12} {
+		a = bits.RotateLeft32(a+(b&c|^b&e)+x[i], 3)
+		e = bits.RotateLeft32(e+(a&b|^a&c)+x[i+1], 7)
+		c = bits.RotateLeft32(c+(e&a|^e&b)+x[i+2], 11)
+		b = bits.RotateLeft32(b+(c&e|^c&a)+x[i+3], 19)
+	}
+
+	// Round 2
+	for _, i := range []int{0, 1, 2, 3} {
+		a = bits.RotateLeft32(a+(b&c|b&e|c&e)+x[i]+0x5a827999, 3)
+		e = bits.RotateLeft32(e+(a&b|a&c|b&c)+x[i+4]+0x5a827999, 5)
+		c = bits.RotateLeft32(c+(e&a|e&b|a&b)+x[i+8]+0x5a827999, 9)
+		b = bits.RotateLeft32(b+(c&e|c&a|e&a)+x[i+12]+0x5a827999, 13)
+	}
+
+	// Round 3
+	for _, i := range []int{0, 2, 1, 3} {
+		a = bits.RotateLeft32(a+(b^c^e)+x[i]+0x6ed9eba1, 3)
+		e = bits.RotateLeft32(e+(a^b^c)+x[i+8]+0x6ed9eba1, 9)
+		c = bits.RotateLeft32(c+(e^a^b)+x[i+4]+0x6ed9eba1, 11)
+		b = bits.RotateLeft32(b+(c^e^a)+x[i+12]+0x6ed9eba1, 15)
+	}
+
+	d.s[0] += a
+	d.s[1] += b
+	d.s[2] += c
+	d.s[3] += e
+}
@@ -0,0 +1,40 @@
+package md4_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/sebastian-mora/basicOTP/internal/md4"
+)
+
+func TestSum(t *testing.T) {
+	// RFC 1320 appendix A.5 test suite
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"", "31d6cfe0d16ae931b73c59d7e0c089c0"},
+		{"a", "bde52cb31de33e46245e05fbdbd6fb24"},
+		{"abc", "a448017aaf21d8525fc10ae87aa6729d"},
+		{"message digest", "d9130a8164549fe818874806e1c7014b"},
+		{"abcdefghijklmnopqrstuvwxyz", "d79e1c308aa5bbcdeea8ed63df412da9"},
+		{"ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789", "043f8582f241db351ce627e153e7f0e4"},
+		{"12345678901234567890123456789012345678901234567890123456789012345678901234567890", "e33b4ddc9c38f2199c3e7b164fcc0536"},
+	}
+
+	for _, tc := range testCases {
+		sum := md4.Sum([]byte(tc.input))
+		if got := hex.EncodeToString(sum[:]); got != tc.expected {
+			t.Errorf("MD4(%q): expected %s, got %s", tc.input, tc.expected, got)
+		}
+
+		// Writing byte by byte must give the same result.
+		h := md4.New()
+		for i := 0; i < len(tc.input); i++ {
+			h.Write([]byte{tc.input[i]})
+		}
+		if got := hex.EncodeToString(h.Sum(nil)); got != tc.expected {
+			t.Errorf("MD4(%q) incremental: expected %s, got %s", tc.input, tc.expected, got)
+		}
+	}
+}
@@ -0,0 +1,392 @@
+package basicOTP
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// MigrationBatch is one part of a Google Authenticator export,
+// encoded as an otpauth-migration://offline?data=... URI.
+// Large exports are split into several batches sharing the same BatchID.
+type MigrationBatch struct {
+	Keys       []Key // Keys are the accounts contained in this batch.
+	Version    int   // Version is the payload format version, currently 1.
+	BatchSize  int   // BatchSize is the number of batches in the export.
+	BatchIndex int   // BatchIndex is the position of this batch in the export, starting at 0.
+	BatchID    int   // BatchID identifies the export the batch belongs to.
+}
+
+// Field numbers and enum values of the MigrationPayload protobuf message.
+const (
+	migrationFieldParameters = 1
+	migrationFieldVersion    = 2
+	migrationFieldBatchSize  = 3
+	migrationFieldBatchIndex = 4
+	migrationFieldBatchID    = 5
+
+	parameterFieldSecret    = 1
+	parameterFieldName      = 2
+	parameterFieldIssuer    = 3
+	parameterFieldAlgorithm = 4
+	parameterFieldDigits    = 5
+	parameterFieldType      = 6
+	parameterFieldCounter   = 7
+
+	migrationDigitsSix   = 1
+	migrationDigitsEight = 2
+
+	migrationTypeHOTP = 1
+	migrationTypeTOTP = 2
+)
+
+// migrationAlgorithms maps the MigrationPayload algorithm enum to a HashType.
+var migrationAlgorithms = map[uint64]HashType{
+	1: SHA1,
+	2: SHA256,
+	3: SHA512,
+}
+
+// ParseMigrationURI decodes an otpauth-migration://offline?data=... URI.
+func ParseMigrationURI(uri string) (MigrationBatch, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return MigrationBatch{}, err
+	}
+
+	if u.Scheme != "otpauth-migration" || u.Host != "offline" {
+		return MigrationBatch{}, fmt.Errorf("basicOTP: unsupported migration URI %q", u.Scheme+"://"+u.Host)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(u.Query().Get("data"))
+	if err != nil {
+		// Some exporters drop the padding
+		data, err = base64.RawStdEncoding.DecodeString(u.Query().Get("data"))
+		if err != nil {
+			return MigrationBatch{}, fmt.Errorf("basicOTP: invalid migration data: %w", err)
+		}
+	}
+
+	return DecodeMigrationPayload(data)
+}
+
+// DecodeMigrationPayload decodes the protobuf MigrationPayload carried in the data parameter.
+func DecodeMigrationPayload(data []byte) (MigrationBatch, error) {
+	var batch MigrationBatch
+	err := readFields(data, func(field int, value uint64, bytes []byte) error {
+		switch field {
+		case migrationFieldParameters:
+			key, err := decodeMigrationParameters(bytes)
+			if err != nil {
+				return err
+			}
+			batch.Keys = append(batch.Keys, key)
+		case migrationFieldVersion:
+			batch.Version = int(int32(value))
+		case migrationFieldBatchSize:
+			batch.BatchSize = int(int32(value))
+			if batch.BatchSize < 0 {
+				return fmt.Errorf("basicOTP: invalid migration batch size %d", batch.BatchSize)
+			}
+		case migrationFieldBatchIndex:
+			batch.BatchIndex = int(int32(value))
+			if batch.BatchIndex < 0 {
+				return fmt.Errorf("basicOTP: invalid migration batch index %d", batch.BatchIndex)
+			}
+		case migrationFieldBatchID:
+			batch.BatchID = int(int32(value))
+		}
+		return nil
+	})
+	return batch, err
+}
+
+// decodeMigrationParameters decodes a single OtpParameters message.
+func decodeMigrationParameters(data []byte) (Key, error) {
+	key := Key{HashType: SHA1, CodeLength: 6, Period: 30}
+	var otpType uint64
+	err := readFields(data, func(field int, value uint64, bytes []byte) error {
+		switch field {
+		case parameterFieldSecret:
+			key.Secret = append([]byte(nil), bytes...)
+		case parameterFieldName:
+			key.Label = string(bytes)
+		case parameterFieldIssuer:
+			key.Issuer = string(bytes)
+		case parameterFieldAlgorithm:
+			if value == 0 {
+				break // unspecified, keep the SHA1 default
+			}
+			hashType, ok := migrationAlgorithms[value]
+			if !ok {
+				return fmt.Errorf("basicOTP: unsupported migration algorithm %d", value)
+			}
+			key.HashType = hashType
+		case parameterFieldDigits:
+			if value == migrationDigitsEight {
+				key.CodeLength = 8
+			}
+		case parameterFieldType:
+			otpType = value
+		case parameterFieldCounter:
+			key.Counter = int(value)
+		}
+		return nil
+	})
+	if err != nil {
+		return Key{}, err
+	}
+
+	switch otpType {
+	case migrationTypeHOTP:
+		key.Type = "hotp"
+	case migrationTypeTOTP:
+		key.Type = "totp"
+	default:
+		return Key{}, fmt.Errorf("basicOTP: unsupported migration OTP type %d", otpType)
+	}
+
+	if len(key.Secret) == 0 {
+		return Key{}, errors.New("basicOTP: migration entry has no secret")
+	}
+	return key, nil
+}
+
+// URI encodes the batch as an otpauth-migration://offline?data=... URI.
+func (b MigrationBatch) URI() (string, error) {
+	data, err := b.Payload()
+	if err != nil {
+		return "", err
+	}
+	return "otpauth-migration://offline?data=" + url.QueryEscape(base64.StdEncoding.EncodeToString(data)), nil
+}
+
+// Payload encodes the batch as a protobuf MigrationPayload.
+// Only SHA1, SHA256 and SHA512 keys with 6 or 8 digits and TOTP keys with a
+// period of 30 seconds can be represented.
+func (b MigrationBatch) Payload() ([]byte, error) {
+	var data []byte
+	for _, key := range b.Keys {
+		parameters, err := encodeMigrationParameters(key)
+		if err != nil {
+			return nil, err
+		}
+		data = appendBytesField(data, migrationFieldParameters, parameters)
+	}
+
+	data = appendVarintField(data, migrationFieldVersion, uint64(b.Version))
+	data = appendVarintField(data, migrationFieldBatchSize, uint64(b.BatchSize))
+	data = appendVarintField(data, migrationFieldBatchIndex, uint64(b.BatchIndex))
+	data = appendVarintField(data, migrationFieldBatchID, uint64(b.BatchID))
+	return data, nil
+}
+
+// encodeMigrationParameters encodes a key as an OtpParameters message.
+func encodeMigrationParameters(key Key) ([]byte, error) {
+	var algorithm uint64
+	for value, hashType := range migrationAlgorithms {
+		if hashType == key.HashType {
+			algorithm = value
+		}
+	}
+	if algorithm == 0 {
+		return nil, fmt.Errorf("basicOTP: algorithm %s is not supported by migration payloads", key.HashType)
+	}
+
+	var digits uint64
+	switch key.CodeLength {
+	case 0, 6:
+		digits = migrationDigitsSix
+	case 8:
+		digits = migrationDigitsEight
+	default:
+		return nil, fmt.Errorf("basicOTP: %d digits are not supported by migration payloads", key.CodeLength)
+	}
+
+	var otpType uint64
+	switch key.Type {
+	case "hotp":
+		otpType = migrationTypeHOTP
+	case "totp":
+		// The payload has no period, importers always use 30 seconds.
+		if key.Period != 0 && key.Period != 30 {
+			return nil, fmt.Errorf("basicOTP: a period of %d seconds is not supported by migration payloads", key.Period)
+		}
+		otpType = migrationTypeTOTP
+	default:
+		return nil, fmt.Errorf("basicOTP: unsupported OTP type %q", key.Type)
+	}
+
+	var data []byte
+	data = appendBytesField(data, parameterFieldSecret, key.Secret)
+	data = appendBytesField(data, parameterFieldName, []byte(key.Label))
+	data = appendBytesField(data, parameterFieldIssuer, []byte(key.Issuer))
+	data = appendVarintField(data, parameterFieldAlgorithm, algorithm)
+	data = appendVarintField(data, parameterFieldDigits, digits)
+	data = appendVarintField(data, parameterFieldType, otpType)
+	data = appendVarintField(data, parameterFieldCounter, uint64(key.Counter))
+	return data, nil
+}
+
+// MigrationURIs encodes keys into one or more migration URIs with at most
+// keysPerBatch keys each, sharing a random batch id.
+// A keysPerBatch of 0 or less puts every key in a single batch.
+func MigrationURIs(keys []Key, keysPerBatch int) ([]string, error) {
+	if keysPerBatch <= 0 || keysPerBatch > len(keys) {
+		keysPerBatch = len(keys)
+	}
+
+	var id [4]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, err
+	}
+	batchID := int(binary.BigEndian.Uint32(id[:]) & 0x7fffffff)
+
+	batchSize := 1
+	if keysPerBatch > 0 {
+		batchSize = (len(keys) + keysPerBatch - 1) / keysPerBatch
+	}
+
+	uris := make([]string, 0, batchSize)
+	for index := 0; index < batchSize; index++ {
+		end := (index + 1) * keysPerBatch
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		uri, err := MigrationBatch{
+			Keys:       keys[index*keysPerBatch : end],
+			Version:    1,
+			BatchSize:  batchSize,
+			BatchIndex: index,
+			BatchID:    batchID,
+		}.URI()
+		if err != nil {
+			return nil, err
+		}
+		uris = append(uris, uri)
+	}
+	return uris, nil
+}
+
+// CombineMigrationBatches joins the keys of every batch of a multi-part export,
+// checking that all batches belong to the same export and none are missing.
+func CombineMigrationBatches(batches []MigrationBatch) ([]Key, error) {
+	if len(batches) == 0 {
+		return nil, errors.New("basicOTP: no migration batches")
+	}
+
+	batchSize := batches[0].BatchSize
+	if batchSize == 0 {
+		batchSize = 1 // single batch exports may omit the size
+	}
+	if batchSize < 0 || batchSize > len(batches) {
+		return nil, fmt.Errorf("basicOTP: %d of %d migration batches are present", len(batches), batchSize)
+	}
+
+	ordered := make([][]Key, batchSize)
+	seen := make([]bool, batchSize)
+	for _, batch := range batches {
+		if batch.BatchID != batches[0].BatchID || batch.BatchSize != batches[0].BatchSize {
+			return nil, errors.New("basicOTP: migration batches belong to different exports")
+		}
+		if batch.BatchIndex < 0 || batch.BatchIndex >= len(ordered) {
+			return nil, fmt.Errorf("basicOTP: migration batch index %d out of range", batch.BatchIndex)
+		}
+		if seen[batch.BatchIndex] {
+			return nil, fmt.Errorf("basicOTP: migration batch %d is duplicated", batch.BatchIndex)
+		}
+		ordered[batch.BatchIndex] = batch.Keys
+		seen[batch.BatchIndex] = true
+	}
+
+	var keys []Key
+	for index, keysInBatch := range ordered {
+		if !seen[index] {
+			return nil, fmt.Errorf("basicOTP: migration batch %d is missing", index)
+		}
+		keys = append(keys, keysInBatch...)
+	}
+	return keys, nil
+}
+
+// Protobuf wire types used by the migration payload.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errTruncatedProtobuf = errors.New("basicOTP: truncated migration payload")
+
+// readFields walks the fields of a protobuf message, calling fn with the
+// field number and either the varint value or the length-delimited bytes.
+// Fixed width fields are skipped.
+func readFields(data []byte, fn func(field int, value uint64, bytes []byte) error) error {
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errTruncatedProtobuf
+		}
+		data = data[n:]
+
+		field := int(tag >> 3)
+		switch tag & 0x7 {
+		case wireVarint:
+			value, n := binary.Uvarint(data)
+			if n <= 0 {
+				return errTruncatedProtobuf
+			}
+			data = data[n:]
+			if err := fn(field, value, nil); err != nil {
+				return err
+			}
+		case wireBytes:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < length {
+				return errTruncatedProtobuf
+			}
+			data = data[n:]
+			if err := fn(field, 0, data[:length]); err != nil {
+				return err
+			}
+			data = data[length:]
+		case wireFixed64:
+			if len(data) < 8 {
+				return errTruncatedProtobuf
+			}
+			data = data[8:]
+		case wireFixed32:
+			if len(data) < 4 {
+				return errTruncatedProtobuf
+			}
+			data = data[4:]
+		default:
+			return fmt.Errorf("basicOTP: unsupported protobuf wire type %d", tag&0x7)
+		}
+	}
+	return nil
+}
+
+// appendVarintField appends a varint field, omitting zero values as proto3 does.
+func appendVarintField(data []byte, field int, value uint64) []byte {
+	if value == 0 {
+		return data
+	}
+	data = binary.AppendUvarint(data, uint64(field)<<3|wireVarint)
+	return binary.AppendUvarint(data, value)
+}
+
+// appendBytesField appends a length-delimited field, omitting empty values as proto3 does.
+func appendBytesField(data []byte, field int, value []byte) []byte {
+	if len(value) == 0 {
+		return data
+	}
+	data = binary.AppendUvarint(data, uint64(field)<<3|wireBytes)
+	data = binary.AppendUvarint(data, uint64(len(value)))
+	return append(data, value...)
+}
@@ -0,0 +1,170 @@
+package basicOTP_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/sebastian-mora/basicOTP"
+)
+
+/*
+The payload below is a MigrationPayload containing a single TOTP entry
+with the secret "Hello!", name "alice", issuer "Example", SHA1 and 6 digits,
+version 1, batch size 1 and batch id 42.
+*/
+const migrationPayloadHex = "0a1e" +
+	"0a0648656c6c6f21" + // secret
+	"1205616c696365" + // name
+	"1a074578616d706c65" + // issuer
+	"2001" + // algorithm SHA1
+	"2801" + // digits SIX
+	"3002" + // type TOTP
+	"1001" + // version
+	"1801" + // batch size
+	"282a" // batch id
+
+func TestParseMigrationURI(t *testing.T) {
+	payload, _ := hex.DecodeString(migrationPayloadHex)
+	uri := "otpauth-migration://offline?data=" + url.QueryEscape(base64.StdEncoding.EncodeToString(payload))
+
+	batch, err := basicOTP.ParseMigrationURI(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if batch.Version != 1 || batch.BatchSize != 1 || batch.BatchIndex != 0 || batch.BatchID != 42 {
+		t.Errorf("Unexpected batch metadata: %+v", batch)
+	}
+	if len(batch.Keys) != 1 {
+		t.Fatalf("Expected 1 key, Got: %d", len(batch.Keys))
+	}
+
+	key := batch.Keys[0]
+	if key.Type != "totp" || key.Label != "alice" || key.Issuer != "Example" || !bytes.Equal(key.Secret, []byte("Hello!")) {
+		t.Errorf("Unexpected key: %+v", key)
+	}
+	if key.HashType != basicOTP.SHA1 || key.CodeLength != 6 || key.Period != 30 {
+		t.Errorf("Unexpected key parameters: %+v", key)
+	}
+
+	// The decoded key matches the URI of the original generator
+	totp := basicOTP.NewTOTP(basicOTP.TOTPConfig{Secret: []byte("Hello!")})
+	if key.TOTP().URI("alice", "Example") != totp.URI("alice", "Example") {
+		t.Error("Decoded TOTP does not match the original")
+	}
+
+	// Encoding produces the same payload
+	encoded, err := batch.Payload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(encoded) != migrationPayloadHex {
+		t.Errorf("Expected payload %s, Got: %x", migrationPayloadHex, encoded)
+	}
+}
+
+func TestMigrationURIsMultiBatch(t *testing.T) {
+	var keys []basicOTP.Key
+	for i := 0; i < 5; i++ {
+		hotp := basicOTP.NewHTOP(basicOTP.HOTPConfig{
+			CodeLength: 8,
+			HashType:   basicOTP.SHA512,
+			Secret:     []byte(fmt.Sprintf("secret-%d", i)),
+			Counter:    i * 1000,
+		})
+		keys = append(keys, hotp.Key(fmt.Sprintf("user-%d", i), "Example"))
+	}
+
+	uris, err := basicOTP.MigrationURIs(keys, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(uris) != 3 {
+		t.Fatalf("Expected 3 batches, Got: %d", len(uris))
+	}
+
+	// Batches can be scanned in any order
+	var batches []basicOTP.MigrationBatch
+	for _, i := range []int{2, 0, 1} {
+		batch, err := basicOTP.ParseMigrationURI(uris[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if batch.BatchIndex != i || batch.BatchSize != 3 {
+			t.Errorf("Unexpected batch metadata: %+v", batch)
+		}
+		batches = append(batches, batch)
+	}
+
+	combined, err := basicOTP.CombineMigrationBatches(batches)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(combined) != len(keys) {
+		t.Fatalf("Expected %d keys, Got: %d", len(keys), len(combined))
+	}
+
+	for i, key := range combined {
+		if key.Label != keys[i].Label || key.Counter != keys[i].Counter || key.HashType != basicOTP.SHA512 || key.CodeLength != 8 {
+			t.Errorf("Key %d did not round trip: %+v", i, key)
+		}
+		if key.HOTP().Generate() != basicOTP.NewHTOP(basicOTP.HOTPConfig{
+			CodeLength: 8,
+			HashType:   basicOTP.SHA512,
+			Secret:     keys[i].Secret,
+			Counter:    keys[i].Counter,
+		}).Generate() {
+			t.Errorf("Key %d generates a different code", i)
+		}
+	}
+
+	if _, err := basicOTP.CombineMigrationBatches(batches[:2]); err == nil {
+		t.Error("Expected an error for a missing batch")
+	}
+	if _, err := basicOTP.CombineMigrationBatches([]basicOTP.MigrationBatch{batches[0], batches[1], batches[1]}); err == nil {
+		t.Error("Expected an error for a duplicated batch")
+	}
+
+	// The batch size is checked against the batches before allocating
+	huge := batches[0]
+	huge.BatchSize = 1<<31 - 1
+	if _, err := basicOTP.CombineMigrationBatches([]basicOTP.MigrationBatch{huge}); err == nil {
+		t.Error("Expected an error for a batch size larger than the batches")
+	}
+}
+
+func TestMigrationErrors(t *testing.T) {
+	unsupported := basicOTP.NewTOTP(basicOTP.TOTPConfig{HashType: basicOTP.SHA384, Secret: []byte("test")})
+	if _, err := basicOTP.MigrationURIs([]basicOTP.Key{unsupported.Key("alice", "")}, 0); err == nil {
+		t.Error("Expected an error encoding SHA384")
+	}
+	slow := basicOTP.NewTOTP(basicOTP.TOTPConfig{TimeInterval: 60, Secret: []byte("test")})
+	if _, err := basicOTP.MigrationURIs([]basicOTP.Key{slow.Key("alice", "")}, 0); err == nil {
+		t.Error("Expected an error encoding a 60 second period")
+	}
+
+	uris := []string{
+		"otpauth://totp/alice?secret=JBSWY3DPEE",
+		"otpauth-migration://offline?data=not-base64!",
+		"otpauth-migration://offline?data=" + url.QueryEscape(base64.StdEncoding.EncodeToString([]byte{0x0a, 0x10, 0x0a})),
+	}
+	for _, uri := range uris {
+		if _, err := basicOTP.ParseMigrationURI(uri); err == nil {
+			t.Errorf("Expected an error parsing %s", uri)
+		}
+	}
+
+	for _, payload := range []string{
+		"18ffffffffffffffffff01", // batch size -1
+		"20ffffffffffffffffff01", // batch index -1
+	} {
+		data, _ := hex.DecodeString(payload)
+		if _, err := basicOTP.DecodeMigrationPayload(data); err == nil {
+			t.Errorf("Expected an error decoding %s", payload)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package basicOTP
+
+import "context"
+
+// EventType identifies what happened to a generator.
+type EventType int
+
+const (
+	EventGenerate        EventType = iota // EventGenerate indicates a code was generated.
+	EventValidateSuccess                  // EventValidateSuccess indicates a code was accepted.
+	EventValidateFailure                  // EventValidateFailure indicates a code was rejected, see Event.Reason.
+	EventResync                           // EventResync indicates the TOTP clock drift estimate changed.
+	EventCounterJump                      // EventCounterJump indicates the HOTP counter was fast-forwarded by look-ahead.
+)
+
+// String returns the name of the event type.
+func (e EventType) String() string {
+	switch e {
+	case EventGenerate:
+		return "generate"
+	case EventValidateSuccess:
+		return "validate_success"
+	case EventValidateFailure:
+		return "validate_failure"
+	case EventResync:
+		return "resync"
+	case EventCounterJump:
+		return "counter_jump"
+	default:
+		return "unknown"
+	}
+}
+
+// Event describes a generate or validate operation. It never holds the secret or the code.
+type Event struct {
+	Type         EventType    // Type is what happened.
+	CredentialID string       // CredentialID is the ID configured for the generator.
+	Counter      int          // Counter is the HOTP counter or TOTP time step involved.
+	Offset       int          // Offset is the drift or look-ahead distance of the matched code.
+	Reason       RejectReason // Reason is why a code was rejected, ReasonNone otherwise.
+	Request      RequestInfo  // Request is the request metadata of the context passed to ValidateContext.
+}
+
+// RequestInfo is metadata about the request a code was submitted with, for audit logging.
+type RequestInfo struct {
+	ClientIP string // ClientIP is the address of the client that submitted the code.
+	UserID   string // UserID identifies the user the code was submitted for.
+}
+
+// requestInfoKey is the context key of the RequestInfo.
+type requestInfoKey struct{}
+
+// WithRequestInfo returns a copy of ctx carrying info, which is added to the
+// events of validations started with ValidateContext.
+func WithRequestInfo(ctx context.Context, info RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, info)
+}
+
+// RequestInfoFromContext returns the RequestInfo carried by ctx, if any.
+func RequestInfoFromContext(ctx context.Context) (RequestInfo, bool) {
+	info, ok := ctx.Value(requestInfoKey{}).(RequestInfo)
+	return info, ok
+}
+
+// Observer is notified of the events of a TOTP or HTOP, for audit logging and metrics.
+// Observe is called synchronously and must not block.
+type Observer interface {
+	Observe(event Event)
+}
+
+// ContextObserver is an Observer that also receives the context of the
+// operation, for example to correlate log records with a trace. If an
+// Observer implements it, ObserveContext is called instead of Observe.
+type ContextObserver interface {
+	Observer
+	ObserveContext(ctx context.Context, event Event)
+}
+
+// ObserverFunc adapts a function to the Observer interface.
+type ObserverFunc func(event Event)
+
+// Observe calls f(event).
+func (f ObserverFunc) Observe(event Event) {
+	f(event)
+}
+
+// observe notifies observer of event if it is set.
+func observe(ctx context.Context, observer Observer, id string, event Event) {
+	if observer == nil {
+		return
+	}
+	event.CredentialID = id
+	event.Request, _ = RequestInfoFromContext(ctx)
+	if o, ok := observer.(ContextObserver); ok {
+		o.ObserveContext(ctx, event)
+		return
+	}
+	observer.Observe(event)
+}
+
+// observeValidation reports a validation result as EventValidateSuccess or EventValidateFailure.
+func observeValidation(ctx context.Context, observer Observer, id string, result ValidationResult) {
+	if result.Valid {
+		observe(ctx, observer, id, Event{Type: EventValidateSuccess, Counter: result.Counter, Offset: result.Offset})
+		return
+	}
+	observe(ctx, observer, id, Event{Type: EventValidateFailure, Counter: result.Counter, Offset: result.Offset, Reason: result.Reason})
+}
@@ -0,0 +1,137 @@
+package basicOTP_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/sebastian-mora/basicOTP"
+)
+
+// recorder collects the events it observes.
+type recorder []basicOTP.Event
+
+func (r *recorder) Observe(event basicOTP.Event) {
+	*r = append(*r, event)
+}
+
+func TestTOTPObserver(t *testing.T) {
+	events := &recorder{}
+	secret := []byte("12345678901234567890")
+	totp := basicOTP.NewTOTP(basicOTP.TOTPConfig{Secret: secret, Window: 1, ID: "alice/phone", Observer: events})
+	client := basicOTP.NewTOTP(basicOTP.TOTPConfig{Secret: secret})
+
+	step := 1706984520 / 30
+	totp.GenerateAt(1706984520)
+	totp.ValidateAt(1706984520, client.GenerateAt(1706984520+30)) // client clock ahead
+	totp.ValidateAt(1706984520+30, "12345")
+
+	expected := recorder{
+		{Type: basicOTP.EventGenerate, CredentialID: "alice/phone", Counter: step},
+		{Type: basicOTP.EventValidateSuccess, CredentialID: "alice/phone", Counter: step + 1, Offset: 1},
+		{Type: basicOTP.EventResync, CredentialID: "alice/phone", Counter: step + 1, Offset: 1},
+		{Type: basicOTP.EventValidateFailure, CredentialID: "alice/phone", Counter: step + 1, Reason: basicOTP.ReasonMalformedCode},
+	}
+	if !reflect.DeepEqual(*events, expected) {
+		t.Errorf("Expected events %+v, got %+v", expected, *events)
+	}
+}
+
+func TestHOTPObserver(t *testing.T) {
+	var events recorder
+	secret := []byte("12345678901234567890")
+	hotp := basicOTP.NewHTOP(basicOTP.HOTPConfig{Secret: secret, SynchronizationLimit: 5, ID: "alice/token", Observer: basicOTP.ObserverFunc(events.Observe)})
+	client := basicOTP.NewHTOP(basicOTP.HOTPConfig{Secret: secret, Counter: 2})
+
+	hotp.Validate(client.Generate())
+	hotp.Validate("000000")
+
+	expected := recorder{
+		{Type: basicOTP.EventValidateSuccess, CredentialID: "alice/token", Counter: 2, Offset: 2},
+		{Type: basicOTP.EventCounterJump, CredentialID: "alice/token", Counter: 2, Offset: 2},
+		{Type: basicOTP.EventValidateFailure, CredentialID: "alice/token", Counter: 2, Reason: basicOTP.ReasonInvalidCode},
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("Expected events %+v, got %+v", expected, events)
+	}
+}
+
+// contextRecorder records the trace ID of the context of each event.
+type contextRecorder struct {
+	recorder
+	traces []string
+}
+
+type traceKey struct{}
+
+func (r *contextRecorder) ObserveContext(ctx context.Context, event basicOTP.Event) {
+	r.Observe(event)
+	trace, _ := ctx.Value(traceKey{}).(string)
+	r.traces = append(r.traces, trace)
+}
+
+func TestValidateContextRequestInfo(t *testing.T) {
+	events := &contextRecorder{}
+	secret := []byte("12345678901234567890")
+	hotp := basicOTP.NewHTOP(basicOTP.HOTPConfig{Secret: secret, ID: "alice/token", Observer: events})
+	client := basicOTP.NewHTOP(basicOTP.HOTPConfig{Secret: secret})
+
+	info := basicOTP.RequestInfo{ClientIP: "192.0.2.1", UserID: "alice"}
+	ctx := basicOTP.WithRequestInfo(context.WithValue(context.Background(), traceKey{}, "trace-1"), info)
+	if got, ok := basicOTP.RequestInfoFromContext(ctx); !ok || got != info {
+		t.Fatalf("Expected request info %+v, got %+v", info, got)
+	}
+
+	if _, err := hotp.ValidateContext(ctx, client.Generate()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	hotp.Validate("12345")
+
+	expected := recorder{
+		{Type: basicOTP.EventValidateSuccess, CredentialID: "alice/token", Counter: 0, Request: info},
+		{Type: basicOTP.EventValidateFailure, CredentialID: "alice/token", Counter: 1, Reason: basicOTP.ReasonMalformedCode},
+	}
+	if !reflect.DeepEqual(events.recorder, expected) {
+		t.Errorf("Expected events %+v, got %+v", expected, events.recorder)
+	}
+	if !reflect.DeepEqual(events.traces, []string{"trace-1", ""}) {
+		t.Errorf("Expected ObserveContext to receive the validation context, got %q", events.traces)
+	}
+}
+
+func TestValidateContextCanceled(t *testing.T) {
+	var events recorder
+	secret := []byte("12345678901234567890")
+	totp := basicOTP.NewTOTP(basicOTP.TOTPConfig{Secret: secret, Observer: &events})
+	hotp := basicOTP.NewHTOP(basicOTP.HOTPConfig{Secret: secret, SynchronizationLimit: 5, Observer: &events})
+	app := basicOTP.NewTOTP(basicOTP.TOTPConfig{Secret: secret})
+	token := basicOTP.NewHTOP(basicOTP.HOTPConfig{Secret: secret})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	code := app.Generate()
+	if result, err := totp.ValidateContext(ctx, code); !errors.Is(err, context.Canceled) || result.Valid {
+		t.Errorf("Expected canceled TOTP validation, got %+v, %v", result, err)
+	}
+	hotpCode := token.Generate()
+	if result, err := hotp.ValidateContext(ctx, hotpCode); !errors.Is(err, context.Canceled) || result.Valid {
+		t.Errorf("Expected canceled HOTP validation, got %+v, %v", result, err)
+	}
+	tx := basicOTP.Transaction{Amount: 1000, Currency: "EUR", Payee: "DE89370400440532013000"}
+	if _, err := totp.ValidateTransactionContext(ctx, app.GenerateTransaction(tx), tx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected canceled transaction validation, got %v", err)
+	}
+	if _, err := totp.ValidateContextAt(ctx, 1706984520, app.GenerateAt(1706984520)); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected canceled validation at a timestamp, got %v", err)
+	}
+	if len(events) != 0 {
+		t.Errorf("Expected no events for canceled validations, got %+v", events)
+	}
+
+	// Nothing was consumed, the codes are still accepted.
+	if !totp.Validate(code) || !hotp.Validate(hotpCode) {
+		t.Error("Expected codes to be accepted after a canceled validation")
+	}
+}
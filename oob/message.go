@@ -0,0 +1,78 @@
+package oob
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Text formats the body of a message, such as
+// "123456 is your Example verification code. It expires in 5 minutes."
+// The code comes first so it is visible in notification previews.
+func (m Message) Text(service string, now time.Time) string {
+	return fmt.Sprintf("%s is your %s verification code. It expires in %s.", m.Code, service, expiresIn(m.ExpiresAt.Sub(now)))
+}
+
+// SMS formats the message for SMS delivery with the WebOTP line appended,
+// so browsers on domain can offer to fill in the code.
+func (m Message) SMS(service string, domain string, now time.Time) string {
+	return m.Text(service, now) + "\n\n" + WebOTPLine(domain, m.Code)
+}
+
+// WebOTPLine returns the "@domain #code" line a browser looks for at the end
+// of an SMS to autofill the code on domain.
+// See: https://wicg.github.io/web-otp/#sms
+func WebOTPLine(domain string, code string) string {
+	return "@" + domain + " #" + code
+}
+
+// expiresIn formats a duration in whole minutes, or seconds below a minute.
+func expiresIn(d time.Duration) string {
+	switch minutes := int(d.Round(time.Minute) / time.Minute); {
+	case d < time.Minute:
+		return fmt.Sprintf("%d seconds", int(d.Round(time.Second)/time.Second))
+	case minutes == 1:
+		return "1 minute"
+	default:
+		return fmt.Sprintf("%d minutes", minutes)
+	}
+}
+
+// MemorySender keeps sent messages in memory, for tests and local development.
+type MemorySender struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+// Send records the message.
+func (s *MemorySender) Send(ctx context.Context, message Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, message)
+	return nil
+}
+
+// Messages returns the messages sent so far.
+func (s *MemorySender) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+// Last returns the last message sent to recipient.
+func (s *MemorySender) Last(recipient string) (Message, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.messages) - 1; i >= 0; i-- {
+		if strings.EqualFold(s.messages[i].Recipient, recipient) {
+			return s.messages[i], true
+		}
+	}
+	return Message{}, false
+}
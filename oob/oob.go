@@ -0,0 +1,209 @@
+// Package oob issues server generated One-Time Passwords that are delivered
+// out of band, for example by SMS or email.
+//
+// Codes are HOTP values computed from a server secret, a counter and the user
+// and purpose they were issued for. Only the counter of the pending code is
+// kept, each code expires after a TTL, allows a limited number of attempts
+// and can be used once.
+package oob
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sebastian-mora/basicOTP"
+)
+
+var (
+	// ErrNotIssued is returned by Verify when no code is pending for the user and purpose.
+	ErrNotIssued = errors.New("oob: no code issued")
+	// ErrExpired is returned by Verify when the pending code is older than the TTL.
+	ErrExpired = errors.New("oob: code expired")
+)
+
+// Message is a code to be delivered to a user.
+type Message struct {
+	Recipient string    // Recipient is the phone number or email address the code is sent to.
+	User      string    // User identifies the user the code was issued for.
+	Purpose   string    // Purpose is what the code authorizes, such as "login" or "reset-password".
+	Code      string    // Code is the One-Time Password.
+	ExpiresAt time.Time // ExpiresAt is the time the code stops being accepted.
+}
+
+// Sender delivers messages to users.
+type Sender interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// Config holds configuration parameters for an Issuer.
+type Config struct {
+	Secret      []byte            // Secret is the server key codes are derived from, it is never sent to users.
+	HashType    basicOTP.HashType // HashType is the hash algorithm used for code generation.
+	CodeLength  int               // CodeLength is the length of the generated codes, defaults to 6.
+	TTL         time.Duration     // TTL is how long a code is accepted, defaults to 5 minutes.
+	MaxAttempts int               // MaxAttempts is the number of wrong codes allowed before the code is discarded, defaults to 3.
+	Sender      Sender            // Sender delivers issued codes.
+	Now         func() time.Time  // Now returns the current time, defaults to time.Now.
+}
+
+// pending is an issued code waiting to be verified.
+type pending struct {
+	counter   int
+	expiresAt time.Time
+	attempts  int
+}
+
+// Issuer mints codes, hands them to a Sender and verifies them.
+// It is safe for concurrent use.
+type Issuer struct {
+	mu      sync.Mutex
+	config  Config
+	otp     basicOTP.OTP
+	counter int
+	pending map[string]*pending
+}
+
+// NewIssuer creates an Issuer based on the provided configuration.
+func NewIssuer(config Config) *Issuer {
+	if len(config.Secret) == 0 {
+		panic("oob requires a Secret")
+	}
+	if config.Sender == nil {
+		panic("oob requires a Sender")
+	}
+	if config.CodeLength == 0 {
+		config.CodeLength = 6
+	}
+	if config.TTL == 0 {
+		config.TTL = 5 * time.Minute
+	}
+	if config.MaxAttempts == 0 {
+		config.MaxAttempts = 3
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+
+	// Start at a random counter so a restarted server does not repeat codes.
+	var start [8]byte
+	if _, err := rand.Read(start[:]); err != nil {
+		panic(err)
+	}
+
+	return &Issuer{
+		config:  config,
+		otp:     basicOTP.NewOTP(config.Secret, config.HashType, config.CodeLength),
+		counter: int(binary.BigEndian.Uint64(start[:]) >> 2),
+		pending: map[string]*pending{},
+	}
+}
+
+// Issue mints a code for user and purpose and sends it to recipient.
+// Issuing a new code replaces the pending one for the same user and purpose.
+// If sending fails no code is pending and the error is returned.
+func (i *Issuer) Issue(ctx context.Context, user string, purpose string, recipient string) (time.Time, error) {
+	i.mu.Lock()
+	now := i.config.Now()
+	i.prune(now)
+
+	i.counter++
+	p := &pending{counter: i.counter, expiresAt: now.Add(i.config.TTL)}
+	key := pendingKey(user, purpose)
+	i.pending[key] = p
+	msg := Message{
+		Recipient: recipient,
+		User:      user,
+		Purpose:   purpose,
+		Code:      i.code(p.counter, user, purpose),
+		ExpiresAt: p.expiresAt,
+	}
+	i.mu.Unlock()
+
+	if err := i.config.Sender.Send(ctx, msg); err != nil {
+		i.mu.Lock()
+		if i.pending[key] == p {
+			delete(i.pending, key)
+		}
+		i.mu.Unlock()
+		return time.Time{}, fmt.Errorf("oob: sending code failed: %w", err)
+	}
+	return msg.ExpiresAt, nil
+}
+
+// Verify checks code against the pending code for user and purpose.
+// A correct code is consumed. Wrong codes count as attempts, once MaxAttempts
+// is reached the pending code is discarded and basicOTP.ErrRateLimited returned.
+// Other rejections return ErrNotIssued, ErrExpired, basicOTP.ErrMalformedCode
+// or basicOTP.ErrInvalidCode.
+func (i *Issuer) Verify(user string, purpose string, code string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	key := pendingKey(user, purpose)
+	p, ok := i.pending[key]
+	if !ok {
+		return ErrNotIssued
+	}
+	if !i.config.Now().Before(p.expiresAt) {
+		delete(i.pending, key)
+		return ErrExpired
+	}
+	if len(code) != i.config.CodeLength {
+		return basicOTP.ErrMalformedCode
+	}
+
+	if subtle.ConstantTimeCompare([]byte(i.code(p.counter, user, purpose)), []byte(code)) == 1 {
+		delete(i.pending, key)
+		return nil
+	}
+
+	p.attempts++
+	if p.attempts >= i.config.MaxAttempts {
+		delete(i.pending, key)
+		return basicOTP.ErrRateLimited
+	}
+	return basicOTP.ErrInvalidCode
+}
+
+// Cancel discards the pending code for user and purpose.
+func (i *Issuer) Cancel(user string, purpose string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	delete(i.pending, pendingKey(user, purpose))
+}
+
+// code computes the code for a counter, user and purpose.
+func (i *Issuer) code(counter int, user string, purpose string) string {
+	return i.otp.GenerateMessage(message(counter, user, purpose))
+}
+
+// prune discards expired pending codes.
+func (i *Issuer) prune(now time.Time) {
+	for key, p := range i.pending {
+		if !now.Before(p.expiresAt) {
+			delete(i.pending, key)
+		}
+	}
+}
+
+// message builds the HMAC message from the 8 byte counter followed by the
+// length prefixed user and purpose, binding a code to what it was issued for.
+func message(counter int, user string, purpose string) []byte {
+	msg := basicOTP.CounterMessage(counter)
+	for _, field := range []string{user, purpose} {
+		msg = binary.BigEndian.AppendUint32(msg, uint32(len(field)))
+		msg = append(msg, field...)
+	}
+	return msg
+}
+
+// pendingKey identifies the pending code of a user and purpose.
+func pendingKey(user string, purpose string) string {
+	return fmt.Sprintf("%d:%s%s", len(user), user, purpose)
+}
@@ -0,0 +1,151 @@
+package oob_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sebastian-mora/basicOTP"
+	"github.com/sebastian-mora/basicOTP/oob"
+)
+
+var issuedAt = time.Date(2024, 2, 3, 18, 0, 0, 0, time.UTC)
+
+// newIssuer returns an issuer with a controllable clock and the sender it delivers to.
+func newIssuer(now *time.Time) (*oob.Issuer, *oob.MemorySender) {
+	sender := &oob.MemorySender{}
+	issuer := oob.NewIssuer(oob.Config{
+		Secret: []byte("12345678901234567890"),
+		Sender: sender,
+		Now:    func() time.Time { return *now },
+	})
+	return issuer, sender
+}
+
+// failingSender rejects every message.
+type failingSender struct{}
+
+func (failingSender) Send(ctx context.Context, message oob.Message) error {
+	return errors.New("gateway unavailable")
+}
+
+func TestIssueAndVerify(t *testing.T) {
+	now := issuedAt
+	issuer, sender := newIssuer(&now)
+
+	expiresAt, err := issuer.Issue(context.Background(), "alice", "login", "+15550100")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !expiresAt.Equal(issuedAt.Add(5 * time.Minute)) {
+		t.Errorf("Expected expiry %v, got %v", issuedAt.Add(5*time.Minute), expiresAt)
+	}
+
+	message, ok := sender.Last("+15550100")
+	if !ok || len(message.Code) != 6 || message.User != "alice" || message.Purpose != "login" {
+		t.Fatalf("Unexpected message %+v", message)
+	}
+
+	if err := issuer.Verify("alice", "reset-password", message.Code); !errors.Is(err, oob.ErrNotIssued) {
+		t.Errorf("Expected code for another purpose to be rejected, got %v", err)
+	}
+	if err := issuer.Verify("alice", "login", message.Code); err != nil {
+		t.Fatalf("Expected code to be valid, got %v", err)
+	}
+	if err := issuer.Verify("alice", "login", message.Code); !errors.Is(err, oob.ErrNotIssued) {
+		t.Errorf("Expected used code to be rejected, got %v", err)
+	}
+}
+
+func TestIssueReplacesPendingCode(t *testing.T) {
+	now := issuedAt
+	issuer, sender := newIssuer(&now)
+
+	issuer.Issue(context.Background(), "alice", "login", "alice@example.com")
+	first, _ := sender.Last("alice@example.com")
+	issuer.Issue(context.Background(), "alice", "login", "alice@example.com")
+	second, _ := sender.Last("alice@example.com")
+
+	if first.Code == second.Code {
+		t.Fatalf("Expected a new code, got %s twice", first.Code)
+	}
+	if err := issuer.Verify("alice", "login", first.Code); !errors.Is(err, basicOTP.ErrInvalidCode) {
+		t.Errorf("Expected replaced code to be rejected, got %v", err)
+	}
+	if err := issuer.Verify("alice", "login", second.Code); err != nil {
+		t.Errorf("Expected new code to be valid, got %v", err)
+	}
+}
+
+func TestVerifyExpired(t *testing.T) {
+	now := issuedAt
+	issuer, sender := newIssuer(&now)
+
+	issuer.Issue(context.Background(), "alice", "login", "+15550100")
+	message, _ := sender.Last("+15550100")
+
+	now = issuedAt.Add(5 * time.Minute)
+	if err := issuer.Verify("alice", "login", message.Code); !errors.Is(err, oob.ErrExpired) {
+		t.Errorf("Expected expired error, got %v", err)
+	}
+	if err := issuer.Verify("alice", "login", message.Code); !errors.Is(err, oob.ErrNotIssued) {
+		t.Errorf("Expected expired code to be discarded, got %v", err)
+	}
+}
+
+func TestVerifyMaxAttempts(t *testing.T) {
+	now := issuedAt
+	issuer, sender := newIssuer(&now)
+
+	issuer.Issue(context.Background(), "alice", "login", "+15550100")
+	message, _ := sender.Last("+15550100")
+	wrong := "000000"
+	if wrong == message.Code {
+		wrong = "111111"
+	}
+
+	if err := issuer.Verify("alice", "login", "12345"); !errors.Is(err, basicOTP.ErrMalformedCode) {
+		t.Errorf("Expected malformed error, got %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := issuer.Verify("alice", "login", wrong); !errors.Is(err, basicOTP.ErrInvalidCode) {
+			t.Errorf("Attempt %d: expected invalid error, got %v", i, err)
+		}
+	}
+	if err := issuer.Verify("alice", "login", wrong); !errors.Is(err, basicOTP.ErrRateLimited) {
+		t.Errorf("Expected rate limited error, got %v", err)
+	}
+	if err := issuer.Verify("alice", "login", message.Code); !errors.Is(err, oob.ErrNotIssued) {
+		t.Errorf("Expected code to be discarded after too many attempts, got %v", err)
+	}
+}
+
+func TestIssueSendFailure(t *testing.T) {
+	issuer := oob.NewIssuer(oob.Config{Secret: []byte("12345678901234567890"), Sender: failingSender{}})
+
+	if _, err := issuer.Issue(context.Background(), "alice", "login", "+15550100"); err == nil {
+		t.Fatal("Expected send error")
+	}
+	if err := issuer.Verify("alice", "login", "000000"); !errors.Is(err, oob.ErrNotIssued) {
+		t.Errorf("Expected no pending code after a failed send, got %v", err)
+	}
+}
+
+func TestMessageFormatting(t *testing.T) {
+	message := oob.Message{Code: "123456", ExpiresAt: issuedAt.Add(5 * time.Minute)}
+
+	expected := "123456 is your Example verification code. It expires in 5 minutes."
+	if text := message.Text("Example", issuedAt); text != expected {
+		t.Errorf("Expected %q, got %q", expected, text)
+	}
+
+	expected = "123456 is your Example verification code. It expires in 1 minute.\n\n@example.com #123456"
+	if sms := message.SMS("Example", "example.com", issuedAt.Add(4*time.Minute)); sms != expected {
+		t.Errorf("Expected %q, got %q", expected, sms)
+	}
+
+	if text := message.Text("Example", issuedAt.Add(4*time.Minute+30*time.Second)); text != "123456 is your Example verification code. It expires in 30 seconds." {
+		t.Errorf("Unexpected text %q", text)
+	}
+}
@@ -6,8 +6,6 @@ package basicOTP
 import (
 	"crypto/hmac"
 	"crypto/sha1"
-	"crypto/sha256"
-	"crypto/sha512"
 	"encoding/binary"
 	"fmt"
 	"hash"
@@ -18,9 +16,13 @@ import (
 type HashType string
 
 const (
-	SHA1   HashType = "SHA1"
-	SHA256 HashType = "SHA256"
-	SHA512 HashType = "SHA512"
+	SHA1       HashType = "SHA1"
+	SHA224     HashType = "SHA224"
+	SHA256     HashType = "SHA256"
+	SHA384     HashType = "SHA384"
+	SHA512     HashType = "SHA512"
+	SHA512_224 HashType = "SHA512/224"
+	SHA512_256 HashType = "SHA512/256"
 )
 
 // OTP represents a One-Time Password generator.
@@ -29,12 +31,13 @@ type OTP struct {
 	HashType   HashType         // HashType is the type of hash algorithm used.
 	secret     []byte           // secret is the shared secret key used for OTP generation.
 	CodeLength int              // CodeLength is the length of the generated OTP code.
+	offset     int              // offset is the static truncation offset, dynamic truncation is used when negative.
 }
 
 // NewOTP creates a new instance of OTP based on the provided configuration.
 // The function will substitute default values for parameters as per the specification:
 //   - codeLength defaults to 6.
-//   - hashFunc will default to SHA1.
+//   - hashFunc will default to SHA1 if hashType is not registered, see RegisterHash.
 //   - A secret is required but length is not enforced. RFC recommends a shared secret of at least 128 bits.
 func NewOTP(secret []byte, hashType HashType, codeLength int) OTP {
 	if len(secret) <= 0 {
@@ -45,15 +48,8 @@ func NewOTP(secret []byte, hashType HashType, codeLength int) OTP {
 		codeLength = 6 // default in RFC 4226
 	}
 
-	var hashFunc func() hash.Hash
-	switch hashType {
-	case SHA1:
-		hashFunc = sha1.New
-	case SHA256:
-		hashFunc = sha256.New
-	case SHA512:
-		hashFunc = sha512.New
-	default: // if hashType is unknown, default to SHA1
+	hashFunc, ok := LookupHash(hashType)
+	if !ok { // if hashType is unknown, default to SHA1
 		hashFunc = sha1.New
 		hashType = SHA1
 	}
@@ -63,39 +59,72 @@ func NewOTP(secret []byte, hashType HashType, codeLength int) OTP {
 		hashFunc:   hashFunc,
 		HashType:   hashType,
 		CodeLength: codeLength,
+		offset:     DynamicOffset,
+	}
+}
+
+// WithStaticOffset returns a copy of the OTP that truncates the HMAC result
+// at a fixed offset instead of using dynamic truncation, as allowed by RFC 4226 section 5.4.
+// The offset must leave room for 4 bytes of the HMAC result.
+func (o OTP) WithStaticOffset(offset int) OTP {
+	if offset < 0 || offset > o.hashFunc().Size()-4 {
+		panic("OTP static offset is out of range for the hash size")
 	}
+
+	o.offset = offset
+	return o
 }
 
 // Generate generates an OTP code based on the provided input.
 func (o OTP) Generate(input int) string {
-	hmac := hmac.New(o.hashFunc, []byte(o.secret))
-	buf := itob(input)
+	return o.GenerateMessage(CounterMessage(input))
+}
 
-	hmac.Write(buf)
-	hmacData := hmac.Sum(nil)
-	code := truncate(hmacData, o.CodeLength)
+// GenerateMessage generates an OTP code from an arbitrary message,
+// for schemes such as OCRA where the HMAC input is not a plain counter.
+func (o OTP) GenerateMessage(message []byte) string {
+	code := int(o.Value(message)) % int(math.Pow10(o.CodeLength))
 
 	formatString := fmt.Sprintf("%%0%dd", o.CodeLength)
 	return fmt.Sprintf(formatString, code)
 }
 
-// truncate truncates the HMAC result to the desired length.
-// The dynamic truncation (DT) algorithm is found in RFC 4226.
-func truncate(input []byte, codeLength int) int {
-	offset := int(input[len(input)-1] & 0xf)
-	code := ((int(input[offset]) & 0x7f) << 24) |
-		((int(input[offset+1] & 0xff)) << 16) |
-		((int(input[offset+2] & 0xff)) << 8) |
-		(int(input[offset+3]) & 0xff)
+// Value returns the raw 31-bit truncation value of the HMAC of message
+// before it is reduced to CodeLength digits.
+func (o OTP) Value(message []byte) uint32 {
+	sum := o.Sum(message)
+	if o.offset < 0 {
+		return Truncate(sum)
+	}
+	return TruncateAt(sum, o.offset)
+}
+
+// Sum returns the raw HMAC of message keyed with the OTP secret.
+func (o OTP) Sum(message []byte) []byte {
+	hmac := hmac.New(o.hashFunc, []byte(o.secret))
+	hmac.Write(message)
+	return hmac.Sum(nil)
+}
+
+// DynamicOffset selects dynamic truncation where the offset is taken from the HMAC result.
+const DynamicOffset = -1
 
-	code = code % int(math.Pow10(codeLength))
+// Truncate returns the 31-bit value of an HMAC result using the
+// dynamic truncation (DT) algorithm found in RFC 4226.
+func Truncate(input []byte) uint32 {
+	offset := int(input[len(input)-1] & 0xf)
+	return TruncateAt(input, offset)
+}
 
-	return code
+// TruncateAt returns the 31-bit value of the 4 bytes of an HMAC result
+// starting at offset, with the most significant bit masked.
+func TruncateAt(input []byte, offset int) uint32 {
+	return binary.BigEndian.Uint32(input[offset:offset+4]) & 0x7fffffff
 }
 
-// itob converts an integer to a big-endian byte array.
-func itob(integer int) []byte {
+// CounterMessage converts a counter to the 8 byte big-endian message used by HOTP and TOTP.
+func CounterMessage(counter int) []byte {
 	byteArr := make([]byte, 8)
-	binary.BigEndian.PutUint64(byteArr, uint64(integer))
+	binary.BigEndian.PutUint64(byteArr, uint64(counter))
 	return byteArr
 }
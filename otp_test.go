@@ -1,6 +1,8 @@
 package basicOTP_test
 
 import (
+	"bytes"
+	"encoding/hex"
 	"strconv"
 	"testing"
 
@@ -103,3 +105,60 @@ func TestNewOTPDefaultHash(t *testing.T) {
 		t.Errorf("Expected default hash function to be sha1, got %v", otp.HashType)
 	}
 }
+
+func TestTruncate(t *testing.T) {
+	// Example from RFC 4226 section 5.4
+	sum, _ := hex.DecodeString("1f8698690e02ca16618550ef7f19da8e945b555a")
+
+	if value := basicOTP.Truncate(sum); value != 0x50ef7f19 {
+		t.Errorf("Expected dynamic truncation 0x50ef7f19, Got: %#x", value)
+	}
+
+	// Static truncation masks the most significant bit
+	if value := basicOTP.TruncateAt(sum, 0); value != 0x1f869869 {
+		t.Errorf("Expected static truncation 0x1f869869, Got: %#x", value)
+	}
+	if value := basicOTP.TruncateAt(sum, 16); value != 0x145b555a {
+		t.Errorf("Expected static truncation 0x145b555a, Got: %#x", value)
+	}
+}
+
+func TestOTPValue(t *testing.T) {
+	otp := basicOTP.NewOTP([]byte("12345678901234567890"), basicOTP.SHA1, 6)
+
+	// Decimal values from RFC 4226 Appendix D
+	if value := otp.Value(basicOTP.CounterMessage(0)); value != 1284755224 {
+		t.Errorf("Expected 1284755224, Got: %d", value)
+	}
+
+	if code := otp.GenerateMessage(basicOTP.CounterMessage(1)); code != otp.Generate(1) {
+		t.Errorf("GenerateMessage and Generate disagree, Got: %s", code)
+	}
+
+	sum := otp.Sum(basicOTP.CounterMessage(0))
+	expected, _ := hex.DecodeString("cc93cf18508d94934c64b65d8ba7667fb7cde4b0")
+	if !bytes.Equal(sum, expected) {
+		t.Errorf("Expected HMAC %x, Got: %x", expected, sum)
+	}
+}
+
+func TestOTPStaticOffset(t *testing.T) {
+	otp := basicOTP.NewOTP([]byte("12345678901234567890"), basicOTP.SHA1, 6).WithStaticOffset(0)
+
+	message := []byte("transaction:42")
+	expected := basicOTP.TruncateAt(otp.Sum(message), 0)
+	if value := otp.Value(message); value != expected {
+		t.Errorf("Expected %d, Got: %d", expected, value)
+	}
+}
+
+func TestOTPStaticOffsetPanic(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("The code did not panic")
+		}
+	}()
+
+	// SHA1 produces 20 bytes so the largest offset is 16
+	basicOTP.NewOTP([]byte("test"), basicOTP.SHA1, 6).WithStaticOffset(17)
+}
@@ -0,0 +1,158 @@
+// Package otphttp provides net/http middleware that protects handlers with
+// a One-Time Password. The code is read from a header or form field and
+// validated against the generator of the requesting user.
+package otphttp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/sebastian-mora/basicOTP"
+)
+
+// Resolver returns the generator of the user making the request.
+// It returns a nil validator if the user has no OTP credential and an error
+// if the lookup failed.
+type Resolver func(r *http.Request) (basicOTP.ContextValidator, error)
+
+// Config holds configuration parameters for the middleware.
+type Config struct {
+	Resolver   Resolver         // Resolver looks up the generator of the requesting user.
+	Header     string           // Header is the request header holding the code, defaults to "X-OTP".
+	FormField  string           // FormField is the POST form field holding the code if the header is absent, defaults to "otp".
+	Realm      string           // Realm is reported in the WWW-Authenticate header, defaults to "otp".
+	JSONErrors bool             // JSONErrors replies with a JSON error body instead of plain text.
+	Now        func() time.Time // Now returns the step-up time recorded in the context, defaults to time.Now.
+
+	// UserID returns the ID of the requesting user reported to observers, if set.
+	// The client IP is taken from the remote address of the request, unless the
+	// request context already carries a basicOTP.RequestInfo, for example set
+	// by a middleware that trusts a proxy header.
+	UserID func(r *http.Request) string
+}
+
+// Error codes reported in the WWW-Authenticate header and JSON error body.
+const (
+	ErrorMissingCode   = "missing_code"
+	ErrorMalformedCode = "malformed_code"
+	ErrorInvalidCode   = "invalid_code"
+	ErrorReplayedCode  = "replayed_code"
+)
+
+// errorBody is the JSON body written when a request is rejected.
+type errorBody struct {
+	Error       string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+type contextKey struct{}
+
+// StepUpTime returns the time the request was authenticated with an OTP.
+func StepUpTime(ctx context.Context) (time.Time, bool) {
+	t, ok := ctx.Value(contextKey{}).(time.Time)
+	return t, ok
+}
+
+// NewMiddleware creates middleware that only calls the next handler once the
+// request carries a valid code. Rejected requests receive a 401 response.
+func NewMiddleware(config Config) func(http.Handler) http.Handler {
+	if config.Resolver == nil {
+		panic("otphttp requires a Resolver")
+	}
+	if config.Header == "" {
+		config.Header = "X-OTP"
+	}
+	if config.FormField == "" {
+		config.FormField = "otp"
+	}
+	if config.Realm == "" {
+		config.Realm = "otp"
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			code := r.Header.Get(config.Header)
+			if code == "" {
+				code = r.PostFormValue(config.FormField) // never the query string, which ends up in logs
+			}
+			if code == "" {
+				config.unauthorized(w, ErrorMissingCode, "an OTP code is required")
+				return
+			}
+
+			validator, err := config.Resolver(r)
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			if validator == nil {
+				config.unauthorized(w, ErrorInvalidCode, "the OTP code is not valid")
+				return
+			}
+
+			_, err = validator.ValidateContext(config.requestContext(r), code)
+			var validationErr *basicOTP.ValidationError
+			if err != nil && !errors.As(err, &validationErr) {
+				// Canceled or a pluggable component such as the replay cache failed.
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			if err != nil {
+				config.unauthorized(w, errorCode(err), "the OTP code is not valid")
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), contextKey{}, config.Now())
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// requestContext returns the context of r carrying the RequestInfo of the request.
+func (c Config) requestContext(r *http.Request) context.Context {
+	info, _ := basicOTP.RequestInfoFromContext(r.Context())
+	if info.ClientIP == "" {
+		info.ClientIP = r.RemoteAddr
+		if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			info.ClientIP = host
+		}
+	}
+	if info.UserID == "" && c.UserID != nil {
+		info.UserID = c.UserID(r)
+	}
+	return basicOTP.WithRequestInfo(r.Context(), info)
+}
+
+// unauthorized writes a 401 response with a WWW-Authenticate challenge.
+func (c Config) unauthorized(w http.ResponseWriter, code string, description string) {
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf("OTP realm=%q, error=%q", c.Realm, code))
+
+	if !c.JSONErrors {
+		http.Error(w, description, http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	json.NewEncoder(w).Encode(errorBody{Error: code, Description: description})
+}
+
+// errorCode maps a validation error to the reported error code.
+func errorCode(err error) string {
+	switch {
+	case errors.Is(err, basicOTP.ErrMalformedCode):
+		return ErrorMalformedCode
+	case errors.Is(err, basicOTP.ErrReplayedCode):
+		return ErrorReplayedCode
+	default:
+		return ErrorInvalidCode
+	}
+}
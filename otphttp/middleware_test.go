@@ -0,0 +1,176 @@
+package otphttp_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sebastian-mora/basicOTP"
+	"github.com/sebastian-mora/basicOTP/otphttp"
+)
+
+var stepUp = time.Date(2024, 2, 3, 18, 0, 0, 0, time.UTC)
+
+// newServer returns a handler protected by the middleware and a client generator for alice.
+func newServer(jsonErrors bool) (http.Handler, *basicOTP.TOTP) {
+	secret := []byte("12345678901234567890")
+	users := map[string]basicOTP.ContextValidator{
+		"alice": basicOTP.NewTOTP(basicOTP.TOTPConfig{Secret: secret, Window: 1}),
+		"carol": basicOTP.NewHTOP(basicOTP.HOTPConfig{Secret: secret, SynchronizationLimit: 10}),
+	}
+
+	middleware := otphttp.NewMiddleware(otphttp.Config{
+		Resolver: func(r *http.Request) (basicOTP.ContextValidator, error) {
+			user := r.URL.Query().Get("user")
+			if user == "broken" {
+				return nil, errors.New("store unavailable")
+			}
+			return users[user], nil
+		},
+		JSONErrors: jsonErrors,
+		Now:        func() time.Time { return stepUp },
+	})
+
+	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if at, ok := otphttp.StepUpTime(r.Context()); !ok || !at.Equal(stepUp) {
+			http.Error(w, "missing step-up time", http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+
+	return handler, basicOTP.NewTOTP(basicOTP.TOTPConfig{Secret: secret})
+}
+
+func TestMiddlewareHeader(t *testing.T) {
+	handler, client := newServer(false)
+
+	request := httptest.NewRequest(http.MethodGet, "/?user=alice", nil)
+	request.Header.Set("X-OTP", client.Generate())
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+
+	if recorder.Code != http.StatusOK || recorder.Body.String() != "ok" {
+		t.Errorf("Expected 200 ok, Got: %d %s", recorder.Code, recorder.Body.String())
+	}
+
+	// The same code can not be used twice
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+
+	if recorder.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401, Got: %d", recorder.Code)
+	}
+	if challenge := recorder.Header().Get("WWW-Authenticate"); challenge != `OTP realm="otp", error="replayed_code"` {
+		t.Errorf("Unexpected challenge: %s", challenge)
+	}
+}
+
+func TestMiddlewareFormField(t *testing.T) {
+	handler, _ := newServer(false)
+
+	form := url.Values{"otp": {"755224"}} // HOTP code for counter 0
+	request := httptest.NewRequest(http.MethodPost, "/?user=carol", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Expected 200, Got: %d %s", recorder.Code, recorder.Body.String())
+	}
+
+	// A code in the query string is ignored
+	request = httptest.NewRequest(http.MethodPost, "/?user=carol&otp=287082", nil)
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+
+	if recorder.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401, Got: %d %s", recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestMiddlewareRejections(t *testing.T) {
+	testCases := []struct {
+		name     string
+		user     string
+		code     string
+		status   int
+		expected string
+	}{
+		{"missing code", "alice", "", http.StatusUnauthorized, otphttp.ErrorMissingCode},
+		{"malformed code", "alice", "12345", http.StatusUnauthorized, otphttp.ErrorMalformedCode},
+		{"invalid code", "carol", "000000", http.StatusUnauthorized, otphttp.ErrorInvalidCode},
+		{"unknown user", "mallory", "000000", http.StatusUnauthorized, otphttp.ErrorInvalidCode},
+		{"resolver error", "broken", "000000", http.StatusInternalServerError, ""},
+	}
+
+	handler, _ := newServer(true)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/?user="+tc.user, nil)
+			if tc.code != "" {
+				request.Header.Set("X-OTP", tc.code)
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+
+			if recorder.Code != tc.status {
+				t.Fatalf("Expected %d, Got: %d", tc.status, recorder.Code)
+			}
+			if tc.expected == "" {
+				return
+			}
+
+			var body struct {
+				Error string `json:"error"`
+			}
+			if err := json.NewDecoder(recorder.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Error != tc.expected {
+				t.Errorf("Expected error %s, Got: %s", tc.expected, body.Error)
+			}
+			if recorder.Header().Get("Content-Type") != "application/json" {
+				t.Errorf("Expected a JSON response, Got: %s", recorder.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestMiddlewareRequestContext(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	var events []basicOTP.Event
+	totp := basicOTP.NewTOTP(basicOTP.TOTPConfig{Secret: secret, ID: "alice/phone", Observer: basicOTP.ObserverFunc(func(event basicOTP.Event) {
+		events = append(events, event)
+	})})
+
+	handler := otphttp.NewMiddleware(otphttp.Config{
+		Resolver: func(r *http.Request) (basicOTP.ContextValidator, error) { return totp, nil },
+		UserID:   func(r *http.Request) string { return "alice" },
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Set("X-OTP", basicOTP.NewTOTP(basicOTP.TOTPConfig{Secret: secret}).Generate())
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+
+	expected := basicOTP.RequestInfo{ClientIP: "192.0.2.1", UserID: "alice"}
+	if recorder.Code != http.StatusOK || len(events) != 1 || events[0].Request != expected {
+		t.Fatalf("Expected request info %+v, Got: %d %+v", expected, recorder.Code, events)
+	}
+
+	// A canceled request is neither accepted nor rejected.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request.WithContext(ctx))
+	if recorder.Code != http.StatusInternalServerError || len(events) != 1 {
+		t.Errorf("Expected 500 without events, Got: %d %+v", recorder.Code, events)
+	}
+}
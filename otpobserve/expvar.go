@@ -0,0 +1,53 @@
+// Package otpobserve provides basicOTP.Observer implementations that turn
+// generate and validate events into log records and metrics.
+package otpobserve
+
+import (
+	"expvar"
+	"strings"
+
+	"github.com/sebastian-mora/basicOTP"
+)
+
+// Expvar counts events in expvar maps, published under a name of the caller's choosing:
+//
+//	{"generate": 3, "validate_success": 2, "validate_failure": 1, "failures": {"invalid_code": 1}}
+type Expvar struct {
+	events   *expvar.Map
+	failures *expvar.Map
+}
+
+// NewExpvar creates counters published as name. Like expvar.NewMap it panics
+// if name is already in use, create it once and share it between generators.
+func NewExpvar(name string) *Expvar {
+	return NewExpvarMap(expvar.NewMap(name))
+}
+
+// NewExpvarMap creates counters kept in events, which the caller may publish
+// with expvar.Publish, nest in another map or leave unpublished.
+func NewExpvarMap(events *expvar.Map) *Expvar {
+	failures := new(expvar.Map)
+	events.Set("failures", failures)
+	return &Expvar{events: events, failures: failures}
+}
+
+// Observe increments the counter of the event type and, for failures, of the reject reason.
+func (e *Expvar) Observe(event basicOTP.Event) {
+	e.events.Add(event.Type.String(), 1)
+	if event.Type == basicOTP.EventValidateFailure {
+		e.failures.Add(strings.ReplaceAll(event.Reason.String(), " ", "_"), 1)
+	}
+}
+
+// Count returns the number of events of the given type.
+func (e *Expvar) Count(eventType basicOTP.EventType) int64 {
+	if v, ok := e.events.Get(eventType.String()).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
+
+// String returns the counters as JSON, so an Expvar is also an expvar.Var.
+func (e *Expvar) String() string {
+	return e.events.String()
+}
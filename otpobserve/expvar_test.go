@@ -0,0 +1,28 @@
+package otpobserve_test
+
+import (
+	"expvar"
+	"testing"
+
+	"github.com/sebastian-mora/basicOTP"
+	"github.com/sebastian-mora/basicOTP/otpobserve"
+)
+
+func TestExpvar(t *testing.T) {
+	counters := otpobserve.NewExpvarMap(new(expvar.Map))
+	hotp := basicOTP.NewHTOP(basicOTP.HOTPConfig{Secret: []byte("12345678901234567890"), SynchronizationLimit: 5, Observer: counters})
+	client := basicOTP.NewHTOP(basicOTP.HOTPConfig{Secret: []byte("12345678901234567890")})
+
+	client.Generate()
+	hotp.Validate(client.Generate())
+	hotp.Validate("12345")
+	hotp.Validate("000000")
+
+	expected := `{"counter_jump": 1, "failures": {"invalid_code": 1, "malformed_code": 1}, "validate_failure": 2, "validate_success": 1}`
+	if got := counters.String(); got != expected {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+	if counters.Count(basicOTP.EventValidateFailure) != 2 || counters.Count(basicOTP.EventGenerate) != 0 {
+		t.Error("Unexpected counts")
+	}
+}
@@ -0,0 +1,58 @@
+//go:build go1.21
+
+package otpobserve
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/sebastian-mora/basicOTP"
+)
+
+// Slog writes events to a structured logger. Generate events are logged at
+// debug level, rejected codes at warn level and everything else at info level.
+type Slog struct {
+	logger *slog.Logger
+}
+
+// NewSlog creates an observer logging to logger, slog.Default() if nil.
+func NewSlog(logger *slog.Logger) *Slog {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Slog{logger: logger}
+}
+
+// Observe logs the event.
+func (s *Slog) Observe(event basicOTP.Event) {
+	s.ObserveContext(context.Background(), event)
+}
+
+// ObserveContext logs the event, passing ctx to the handler of the logger.
+// The client IP and user ID are logged if the validation context carried them.
+func (s *Slog) ObserveContext(ctx context.Context, event basicOTP.Event) {
+	level := slog.LevelInfo
+	switch event.Type {
+	case basicOTP.EventGenerate:
+		level = slog.LevelDebug
+	case basicOTP.EventValidateFailure:
+		level = slog.LevelWarn
+	}
+
+	attrs := []slog.Attr{
+		slog.String("event", event.Type.String()),
+		slog.String("credential_id", event.CredentialID),
+		slog.Int("counter", event.Counter),
+		slog.Int("offset", event.Offset),
+	}
+	if event.Reason != basicOTP.ReasonNone {
+		attrs = append(attrs, slog.String("reason", event.Reason.String()))
+	}
+	if event.Request.ClientIP != "" {
+		attrs = append(attrs, slog.String("client_ip", event.Request.ClientIP))
+	}
+	if event.Request.UserID != "" {
+		attrs = append(attrs, slog.String("user_id", event.Request.UserID))
+	}
+	s.logger.LogAttrs(ctx, level, "otp "+event.Type.String(), attrs...)
+}
@@ -0,0 +1,51 @@
+//go:build go1.21
+
+package otpobserve_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/sebastian-mora/basicOTP"
+	"github.com/sebastian-mora/basicOTP/otpobserve"
+)
+
+func TestSlog(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+
+	secret := []byte("12345678901234567890")
+	totp := basicOTP.NewTOTP(basicOTP.TOTPConfig{Secret: secret, ID: "alice/phone", Observer: otpobserve.NewSlog(logger)})
+	code := basicOTP.NewTOTP(basicOTP.TOTPConfig{Secret: secret}).GenerateAt(1706984520)
+	totp.ValidateAt(1706984520, code)
+	totp.ValidateAt(1706984520, code)
+	totp.ValidateContextAt(basicOTP.WithRequestInfo(context.Background(), basicOTP.RequestInfo{ClientIP: "192.0.2.1", UserID: "alice"}), 1706984520, "12345")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	expected := []string{
+		`level=INFO msg="otp validate_success" event=validate_success credential_id=alice/phone counter=56899484 offset=0`,
+		`level=WARN msg="otp validate_failure" event=validate_failure credential_id=alice/phone counter=56899484 offset=0 reason="replayed code"`,
+		`level=WARN msg="otp validate_failure" event=validate_failure credential_id=alice/phone counter=56899484 offset=0 reason="malformed code" client_ip=192.0.2.1 user_id=alice`,
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("Expected %d lines, got %q", len(expected), buf.String())
+	}
+	for i := range expected {
+		if lines[i] != expected[i] {
+			t.Errorf("Expected %s, got %s", expected[i], lines[i])
+		}
+	}
+	if strings.Contains(buf.String(), code) {
+		t.Error("Expected the code to never be logged")
+	}
+}
@@ -0,0 +1,331 @@
+// Package pamfile reads and writes the ~/.google_authenticator files used by
+// the google-authenticator PAM module.
+//
+// A file starts with the Base32 secret, followed by option lines starting
+// with a double quote and the single-use 8 digit scratch codes:
+//
+//	JBSWY3DPEHPK3PXP
+//	" RATE_LIMIT 3 30 1706984502
+//	" WINDOW_SIZE 17
+//	" DISALLOW_REUSE 56899483
+//	" TOTP_AUTH
+//	12345678
+package pamfile
+
+import (
+	"crypto/subtle"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sebastian-mora/basicOTP"
+)
+
+const (
+	defaultWindowSize = 3 // defaultWindowSize is the number of codes accepted when WINDOW_SIZE is absent.
+	scratchCodeLength = 8 // scratchCodeLength is the length of emergency scratch codes.
+)
+
+// RateLimit allows at most Attempts logins every Interval seconds.
+type RateLimit struct {
+	Attempts   int     // Attempts is the number of logins allowed in the interval.
+	Interval   int     // Interval is the length of the rate limit interval in seconds.
+	Timestamps []int64 // Timestamps are the Unix times of recent login attempts.
+}
+
+// File is a parsed google_authenticator file.
+type File struct {
+	Secret        []byte     // Secret is the shared secret key.
+	CounterBased  bool       // CounterBased is set by HOTP_COUNTER, otherwise the file is TOTP based.
+	HOTPCounter   int        // HOTPCounter is the next HOTP counter value.
+	StepSize      int        // StepSize is the TOTP time step in seconds, 0 for the default.
+	WindowSize    int        // WindowSize is the number of codes accepted, 0 for the default.
+	RateLimit     *RateLimit // RateLimit limits login attempts when set.
+	DisallowReuse bool       // DisallowReuse rejects TOTP codes for time steps already used.
+	UsedSteps     []int      // UsedSteps are the recently used TOTP time steps.
+	ScratchCodes  []string   // ScratchCodes are the unused single-use emergency codes.
+	Options       []string   // Options are unrecognized option lines, preserved when writing.
+}
+
+// Parse decodes the contents of a google_authenticator file.
+func Parse(data []byte) (*File, error) {
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+
+	secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(strings.TrimSpace(lines[0]), "="))
+	if err != nil || len(secret) == 0 {
+		return nil, errors.New("pamfile: invalid secret")
+	}
+
+	f := &File{Secret: secret}
+	for _, line := range lines[1:] {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if !strings.HasPrefix(line, "\"") {
+			if len(line) != scratchCodeLength || strings.Trim(line, "0123456789") != "" {
+				return nil, fmt.Errorf("pamfile: invalid scratch code %q", line)
+			}
+			f.ScratchCodes = append(f.ScratchCodes, line)
+			continue
+		}
+
+		if err := f.parseOption(line); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// parseOption applies a single option line.
+func (f *File) parseOption(line string) error {
+	fields := strings.Fields(strings.TrimPrefix(line, "\""))
+	if len(fields) == 0 {
+		return nil
+	}
+
+	values := make([]int64, 0, len(fields)-1)
+	for _, field := range fields[1:] {
+		value, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			f.Options = append(f.Options, line) // not one of ours
+			return nil
+		}
+		values = append(values, value)
+	}
+
+	switch fields[0] {
+	case "TOTP_AUTH":
+		f.CounterBased = false
+	case "HOTP_COUNTER":
+		if len(values) != 1 {
+			return errors.New("pamfile: invalid HOTP_COUNTER")
+		}
+		f.CounterBased = true
+		f.HOTPCounter = int(values[0])
+	case "STEP_SIZE":
+		if len(values) != 1 || values[0] <= 0 {
+			return errors.New("pamfile: invalid STEP_SIZE")
+		}
+		f.StepSize = int(values[0])
+	case "WINDOW_SIZE":
+		if len(values) != 1 || values[0] <= 0 {
+			return errors.New("pamfile: invalid WINDOW_SIZE")
+		}
+		f.WindowSize = int(values[0])
+	case "RATE_LIMIT":
+		if len(values) < 2 || values[0] <= 0 || values[1] <= 0 {
+			return errors.New("pamfile: invalid RATE_LIMIT")
+		}
+		f.RateLimit = &RateLimit{Attempts: int(values[0]), Interval: int(values[1]), Timestamps: values[2:]}
+	case "DISALLOW_REUSE":
+		f.DisallowReuse = true
+		for _, value := range values {
+			f.UsedSteps = append(f.UsedSteps, int(value))
+		}
+	default:
+		f.Options = append(f.Options, line)
+	}
+	return nil
+}
+
+// Marshal encodes the file in the google_authenticator format.
+func (f *File) Marshal() []byte {
+	var b strings.Builder
+	b.WriteString(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(f.Secret))
+	b.WriteString("\n")
+
+	if f.RateLimit != nil {
+		fmt.Fprintf(&b, "\" RATE_LIMIT %d %d", f.RateLimit.Attempts, f.RateLimit.Interval)
+		for _, timestamp := range f.RateLimit.Timestamps {
+			fmt.Fprintf(&b, " %d", timestamp)
+		}
+		b.WriteString("\n")
+	}
+	if f.WindowSize != 0 {
+		fmt.Fprintf(&b, "\" WINDOW_SIZE %d\n", f.WindowSize)
+	}
+	if f.DisallowReuse {
+		b.WriteString("\" DISALLOW_REUSE")
+		for _, step := range f.UsedSteps {
+			fmt.Fprintf(&b, " %d", step)
+		}
+		b.WriteString("\n")
+	}
+	if f.CounterBased {
+		fmt.Fprintf(&b, "\" HOTP_COUNTER %d\n", f.HOTPCounter)
+	} else {
+		b.WriteString("\" TOTP_AUTH\n")
+	}
+	if f.StepSize != 0 {
+		fmt.Fprintf(&b, "\" STEP_SIZE %d\n", f.StepSize)
+	}
+	for _, option := range f.Options {
+		b.WriteString(option + "\n")
+	}
+	for _, code := range f.ScratchCodes {
+		b.WriteString(code + "\n")
+	}
+	return []byte(b.String())
+}
+
+// TOTP creates the TOTP generator described by the file, with the window
+// size applied and, when DISALLOW_REUSE is set, the used time steps rejected as replays.
+func (f *File) TOTP() *basicOTP.TOTP {
+	totp := basicOTP.NewTOTP(basicOTP.TOTPConfig{
+		TimeInterval: f.StepSize,
+		CodeLength:   6,
+		HashType:     basicOTP.SHA1,
+		Secret:       f.Secret,
+		Window:       (f.windowSize() - 1) / 2,
+	})
+
+	lastStep := -1
+	if f.DisallowReuse {
+		for _, step := range f.UsedSteps {
+			if step > lastStep {
+				lastStep = step
+			}
+		}
+	}
+	totp.SetState(basicOTP.TOTPState{LastStep: lastStep})
+	return totp
+}
+
+// HOTP creates the HOTP generator described by the file, accepting
+// the next WINDOW_SIZE counter values.
+func (f *File) HOTP() *basicOTP.HTOP {
+	return basicOTP.NewHTOP(basicOTP.HOTPConfig{
+		CodeLength:           6,
+		HashType:             basicOTP.SHA1,
+		Secret:               f.Secret,
+		Counter:              f.HOTPCounter,
+		SynchronizationLimit: f.windowSize(),
+	})
+}
+
+// Validate checks a verification or scratch code at now, applying the rate
+// limit, window and reuse settings. The file is updated with the new state
+// and must be saved afterwards, even if validation failed.
+func (f *File) Validate(code string, now time.Time) (basicOTP.ValidationResult, error) {
+	if !f.allowAttempt(now.Unix()) {
+		return basicOTP.ValidationResult{Reason: basicOTP.ReasonRateLimited}, basicOTP.ErrRateLimited
+	}
+
+	if len(code) == scratchCodeLength {
+		for i, scratch := range f.ScratchCodes {
+			if subtle.ConstantTimeCompare([]byte(scratch), []byte(code)) == 1 {
+				f.ScratchCodes = append(f.ScratchCodes[:i:i], f.ScratchCodes[i+1:]...)
+				return basicOTP.ValidationResult{Valid: true}, nil
+			}
+		}
+	}
+
+	if f.CounterBased {
+		result, err := f.HOTP().ValidateDetailed(code)
+		if err == nil {
+			f.HOTPCounter = result.Counter + 1
+		}
+		return result, err
+	}
+
+	totp := f.TOTP()
+	result, err := totp.ValidateDetailedAt(now.Unix(), code)
+	if err == nil && f.DisallowReuse {
+		f.recordStep(result.Counter, result.Counter-result.Offset)
+	}
+	return result, err
+}
+
+// allowAttempt records a login attempt, reporting whether it is within the rate limit.
+func (f *File) allowAttempt(now int64) bool {
+	if f.RateLimit == nil {
+		return true
+	}
+
+	recent := []int64{}
+	for _, timestamp := range f.RateLimit.Timestamps {
+		if timestamp > now-int64(f.RateLimit.Interval) && timestamp <= now {
+			recent = append(recent, timestamp)
+		}
+	}
+	f.RateLimit.Timestamps = append(recent, now)
+	return len(f.RateLimit.Timestamps) <= f.RateLimit.Attempts
+}
+
+// recordStep adds a used time step, forgetting steps that fell out of the window around current.
+func (f *File) recordStep(step int, current int) {
+	window := (f.windowSize() - 1) / 2
+	used := []int{}
+	for _, s := range f.UsedSteps {
+		if s >= current-window {
+			used = append(used, s)
+		}
+	}
+	f.UsedSteps = append(used, step)
+}
+
+// windowSize returns the configured window size or the default.
+func (f *File) windowSize() int {
+	if f.WindowSize == 0 {
+		return defaultWindowSize
+	}
+	return f.WindowSize
+}
+
+// Load reads a google_authenticator file.
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Save writes the file atomically by renaming a temporary file over path.
+// The file is only readable by its owner, as required by the PAM module.
+func (f *File) Save(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"~")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(f.Marshal()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o400); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// VerifyFile loads the file at path, validates code at now and writes
+// the updated state back before returning the result.
+func VerifyFile(path string, code string, now time.Time) (basicOTP.ValidationResult, error) {
+	f, err := Load(path)
+	if err != nil {
+		return basicOTP.ValidationResult{}, err
+	}
+
+	result, validationErr := f.Validate(code, now)
+	if err := f.Save(path); err != nil {
+		return basicOTP.ValidationResult{}, err
+	}
+	return result, validationErr
+}
@@ -0,0 +1,154 @@
+package pamfile_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sebastian-mora/basicOTP"
+	"github.com/sebastian-mora/basicOTP/pamfile"
+)
+
+// The secret is "12345678901234567890", the RFC 4226 test secret, in Base32.
+const totpFile = `GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ
+" RATE_LIMIT 3 30 1111111000
+" WINDOW_SIZE 3
+" DISALLOW_REUSE
+" TOTP_AUTH
+" UNKNOWN_OPTION foo
+12345678
+87654321
+`
+
+// RFC 6238 Appendix B, SHA1 at 1111111109 is 07081804
+var now = time.Unix(1111111109, 0)
+
+func TestParseAndMarshal(t *testing.T) {
+	f, err := pamfile.Parse([]byte(totpFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(f.Secret) != "12345678901234567890" || f.CounterBased || f.WindowSize != 3 || !f.DisallowReuse {
+		t.Errorf("Unexpected file: %+v", f)
+	}
+	if f.RateLimit == nil || f.RateLimit.Attempts != 3 || f.RateLimit.Interval != 30 || len(f.RateLimit.Timestamps) != 1 {
+		t.Errorf("Unexpected rate limit: %+v", f.RateLimit)
+	}
+	if len(f.ScratchCodes) != 2 || len(f.Options) != 1 {
+		t.Errorf("Unexpected scratch codes or options: %v %v", f.ScratchCodes, f.Options)
+	}
+
+	if string(f.Marshal()) != totpFile {
+		t.Errorf("Marshal did not round trip, Got:\n%s", f.Marshal())
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	files := []string{
+		"not base32!\n",
+		"GEZDGNBV\n\" WINDOW_SIZE 0\n",
+		"GEZDGNBV\n\" RATE_LIMIT 3\n",
+		"GEZDGNBV\n1234\n",
+	}
+
+	for _, data := range files {
+		if _, err := pamfile.Parse([]byte(data)); err == nil {
+			t.Errorf("Expected an error parsing %q", data)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	f, _ := pamfile.Parse([]byte(totpFile))
+
+	result, err := f.Validate("081804", now)
+	if err != nil || !result.Valid {
+		t.Fatalf("Expected a valid code, Got: %+v, %v", result, err)
+	}
+	if len(f.UsedSteps) != 1 || f.UsedSteps[0] != 1111111109/30 {
+		t.Errorf("Used time step was not recorded: %v", f.UsedSteps)
+	}
+
+	if _, err := f.Validate("081804", now); !errors.Is(err, basicOTP.ErrReplayedCode) {
+		t.Errorf("Expected a replayed code, Got: %v", err)
+	}
+
+	// Third attempt within 30 seconds, the timestamp from the file has expired
+	if _, err := f.Validate("000000", now); !errors.Is(err, basicOTP.ErrInvalidCode) {
+		t.Errorf("Expected an invalid code, Got: %v", err)
+	}
+	if _, err := f.Validate("12345678", now); !errors.Is(err, basicOTP.ErrRateLimited) {
+		t.Errorf("Expected rate limiting, Got: %v", err)
+	}
+
+	// Scratch codes are consumed once the rate limit has passed
+	later := now.Add(time.Minute)
+	if result, err := f.Validate("12345678", later); err != nil || !result.Valid {
+		t.Errorf("Expected the scratch code to be accepted, Got: %v", err)
+	}
+	if _, err := f.Validate("12345678", later); err == nil {
+		t.Error("Scratch code was accepted twice")
+	}
+	if len(f.ScratchCodes) != 1 || f.ScratchCodes[0] != "87654321" {
+		t.Errorf("Scratch code was not removed: %v", f.ScratchCodes)
+	}
+}
+
+func TestValidateTOTPReuseAllowed(t *testing.T) {
+	f, _ := pamfile.Parse([]byte("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ\n\" TOTP_AUTH\n"))
+
+	for i := 0; i < 2; i++ {
+		if result, err := f.Validate("081804", now); err != nil || !result.Valid {
+			t.Errorf("Expected the code to be accepted without DISALLOW_REUSE, Got: %v", err)
+		}
+	}
+}
+
+func TestValidateHOTP(t *testing.T) {
+	f, _ := pamfile.Parse([]byte("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ\n\" WINDOW_SIZE 5\n\" HOTP_COUNTER 1\n"))
+
+	// Counter 3 of RFC 4226 Appendix D is within the window
+	result, err := f.Validate("969429", now)
+	if err != nil || result.Counter != 3 {
+		t.Fatalf("Expected counter 3 to be accepted, Got: %+v, %v", result, err)
+	}
+	if f.HOTPCounter != 4 {
+		t.Errorf("Expected HOTP_COUNTER 4, Got: %d", f.HOTPCounter)
+	}
+
+	if _, err := f.Validate("969429", now); err == nil {
+		t.Error("HOTP code was accepted twice")
+	}
+}
+
+func TestVerifyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".google_authenticator")
+	if err := os.WriteFile(path, []byte(totpFile), 0o400); err != nil {
+		t.Fatal(err)
+	}
+
+	if result, err := pamfile.VerifyFile(path, "081804", now); err != nil || !result.Valid {
+		t.Fatalf("Expected a valid code, Got: %v", err)
+	}
+
+	// The used time step was written back
+	if _, err := pamfile.VerifyFile(path, "081804", now); !errors.Is(err, basicOTP.ErrReplayedCode) {
+		t.Errorf("Expected a replayed code, Got: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o400 {
+		t.Errorf("Expected mode 0400, Got: %v", info.Mode().Perm())
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("Temporary files were left behind: %v", entries)
+	}
+}
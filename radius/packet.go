@@ -0,0 +1,239 @@
+package radius
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+)
+
+// Code is the type of a RADIUS packet.
+type Code byte
+
+const (
+	CodeAccessRequest   Code = 1
+	CodeAccessAccept    Code = 2
+	CodeAccessReject    Code = 3
+	CodeAccessChallenge Code = 11
+)
+
+// AttributeType identifies a RADIUS attribute.
+type AttributeType byte
+
+const (
+	AttributeUserName             AttributeType = 1
+	AttributeUserPassword         AttributeType = 2
+	AttributeReplyMessage         AttributeType = 18
+	AttributeState                AttributeType = 24
+	AttributeMessageAuthenticator AttributeType = 80
+)
+
+const (
+	headerLength  = 20
+	maxPacketSize = 4096
+)
+
+var errMalformedPacket = errors.New("radius: malformed packet")
+
+// Attribute is a single RADIUS attribute.
+type Attribute struct {
+	Type  AttributeType
+	Value []byte
+}
+
+// Packet is a RADIUS packet as defined in RFC 2865 section 3.
+type Packet struct {
+	Code          Code
+	Identifier    byte
+	Authenticator [16]byte
+	Attributes    []Attribute
+}
+
+// ParsePacket decodes a RADIUS packet.
+func ParsePacket(data []byte) (*Packet, error) {
+	if len(data) < headerLength {
+		return nil, errMalformedPacket
+	}
+
+	length := int(binary.BigEndian.Uint16(data[2:4]))
+	if length < headerLength || length > maxPacketSize || length > len(data) {
+		return nil, errMalformedPacket
+	}
+
+	p := &Packet{Code: Code(data[0]), Identifier: data[1]}
+	copy(p.Authenticator[:], data[4:20])
+
+	attributes := data[headerLength:length]
+	for len(attributes) > 0 {
+		if len(attributes) < 2 || attributes[1] < 2 || int(attributes[1]) > len(attributes) {
+			return nil, errMalformedPacket
+		}
+		p.Attributes = append(p.Attributes, Attribute{
+			Type:  AttributeType(attributes[0]),
+			Value: append([]byte(nil), attributes[2:attributes[1]]...),
+		})
+		attributes = attributes[attributes[1]:]
+	}
+	return p, nil
+}
+
+// Marshal encodes the packet as is, without computing any authenticator.
+func (p *Packet) Marshal() ([]byte, error) {
+	data := make([]byte, headerLength, maxPacketSize)
+	data[0] = byte(p.Code)
+	data[1] = p.Identifier
+	copy(data[4:20], p.Authenticator[:])
+
+	for _, attribute := range p.Attributes {
+		if len(attribute.Value) > 253 {
+			return nil, errors.New("radius: attribute value too long")
+		}
+		data = append(data, byte(attribute.Type), byte(len(attribute.Value)+2))
+		data = append(data, attribute.Value...)
+	}
+
+	if len(data) > maxPacketSize {
+		return nil, errors.New("radius: packet too large")
+	}
+	binary.BigEndian.PutUint16(data[2:4], uint16(len(data)))
+	return data, nil
+}
+
+// Get returns the value of the first attribute of the given type.
+func (p *Packet) Get(attributeType AttributeType) ([]byte, bool) {
+	for _, attribute := range p.Attributes {
+		if attribute.Type == attributeType {
+			return attribute.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Add appends an attribute to the packet.
+func (p *Packet) Add(attributeType AttributeType, value []byte) {
+	p.Attributes = append(p.Attributes, Attribute{Type: attributeType, Value: value})
+}
+
+// Response creates a reply to the request with the same identifier.
+// The authenticator is filled in by Sign.
+func (p *Packet) Response(code Code) *Packet {
+	return &Packet{Code: code, Identifier: p.Identifier}
+}
+
+// Sign encodes a response to request, adding a Message-Authenticator if the
+// request carried one and computing the Response Authenticator.
+func (p *Packet) Sign(request *Packet, secret []byte) ([]byte, error) {
+	p.Authenticator = request.Authenticator
+
+	if _, ok := request.Get(AttributeMessageAuthenticator); ok {
+		if err := p.AddMessageAuthenticator(secret); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := p.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	hash := md5.New()
+	hash.Write(data)
+	hash.Write(secret)
+	copy(data[4:20], hash.Sum(nil))
+	copy(p.Authenticator[:], data[4:20])
+	return data, nil
+}
+
+// AddMessageAuthenticator appends a Message-Authenticator computed over the
+// packet with its current Authenticator, as described in RFC 3579 section 3.2.
+func (p *Packet) AddMessageAuthenticator(secret []byte) error {
+	p.Add(AttributeMessageAuthenticator, make([]byte, md5.Size))
+	data, err := p.Marshal()
+	if err != nil {
+		return err
+	}
+
+	mac := hmac.New(md5.New, secret)
+	mac.Write(data)
+	copy(p.Attributes[len(p.Attributes)-1].Value, mac.Sum(nil))
+	return nil
+}
+
+// VerifyResponse checks the Response Authenticator of a reply to request.
+func VerifyResponse(response []byte, request *Packet, secret []byte) bool {
+	if len(response) < headerLength {
+		return false
+	}
+
+	data := append([]byte(nil), response...)
+	copy(data[4:20], request.Authenticator[:])
+
+	hash := md5.New()
+	hash.Write(data)
+	hash.Write(secret)
+	return hmac.Equal(hash.Sum(nil), response[4:20])
+}
+
+// verifyMessageAuthenticator checks the Message-Authenticator of a request
+// as described in RFC 3579 section 3.2. Requests without one are accepted.
+func (p *Packet) verifyMessageAuthenticator(secret []byte) bool {
+	expected, ok := p.Get(AttributeMessageAuthenticator)
+	if !ok {
+		return true
+	}
+
+	zeroed := &Packet{Code: p.Code, Identifier: p.Identifier, Authenticator: p.Authenticator}
+	for _, attribute := range p.Attributes {
+		if attribute.Type == AttributeMessageAuthenticator {
+			attribute.Value = make([]byte, len(attribute.Value))
+		}
+		zeroed.Attributes = append(zeroed.Attributes, attribute)
+	}
+
+	data, err := zeroed.Marshal()
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(md5.New, secret)
+	mac.Write(data)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// EncryptPassword hides a User-Password as described in RFC 2865 section 5.2.
+func EncryptPassword(password []byte, secret []byte, authenticator [16]byte) []byte {
+	padded := make([]byte, (len(password)+15)/16*16)
+	if len(padded) == 0 {
+		padded = make([]byte, 16)
+	}
+	copy(padded, password)
+
+	previous := authenticator[:]
+	for i := 0; i < len(padded); i += 16 {
+		b := md5.Sum(append(append([]byte(nil), secret...), previous...))
+		for j := 0; j < 16; j++ {
+			padded[i+j] ^= b[j]
+		}
+		previous = padded[i : i+16]
+	}
+	return padded
+}
+
+// DecryptPassword reveals a User-Password hidden with EncryptPassword.
+func DecryptPassword(hidden []byte, secret []byte, authenticator [16]byte) ([]byte, error) {
+	if len(hidden) == 0 || len(hidden)%16 != 0 || len(hidden) > 128 {
+		return nil, errMalformedPacket
+	}
+
+	password := make([]byte, len(hidden))
+	previous := authenticator[:]
+	for i := 0; i < len(hidden); i += 16 {
+		b := md5.Sum(append(append([]byte(nil), secret...), previous...))
+		for j := 0; j < 16; j++ {
+			password[i+j] = hidden[i+j] ^ b[j]
+		}
+		previous = hidden[i : i+16]
+	}
+	return bytes.TrimRight(password, "\x00"), nil
+}
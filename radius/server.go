@@ -0,0 +1,257 @@
+// Package radius is a minimal RADIUS (RFC 2865) front-end that validates
+// One-Time Passwords for network access servers such as VPN concentrators.
+//
+// Access-Request packets carry the OTP in the PAP User-Password attribute.
+// When a PasswordVerifier is configured the server uses a two-step flow:
+// the first request carries the password and is answered with an
+// Access-Challenge, the second request carries the OTP and the State of the challenge.
+package radius
+
+import (
+	"crypto/rand"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/sebastian-mora/basicOTP"
+)
+
+// Config holds configuration parameters for the RADIUS server.
+type Config struct {
+	Secret           []byte                                           // Secret is the shared secret with the RADIUS clients.
+	Resolver         func(user string) (basicOTP.Validator, error)    // Resolver returns the generator of a user, nil if the user is unknown.
+	PasswordVerifier func(user string, password string) (bool, error) // PasswordVerifier enables the password then OTP challenge flow when set.
+	ChallengeTimeout time.Duration                                    // ChallengeTimeout is how long a challenge can be answered, defaults to one minute.
+	ChallengeMessage string                                           // ChallengeMessage is sent as Reply-Message with a challenge.
+	DuplicateTimeout time.Duration                                    // DuplicateTimeout is how long a response is resent to retransmissions of its request, defaults to 5 seconds.
+
+	// RequireMessageAuthenticator discards Access-Requests without a
+	// Message-Authenticator instead of only verifying it when present.
+	RequireMessageAuthenticator bool
+}
+
+// challenge is a pending password then OTP authentication.
+type challenge struct {
+	user    string
+	expires time.Time
+}
+
+// reply is a response kept to answer retransmissions of its request.
+type reply struct {
+	data    []byte
+	expires time.Time
+}
+
+// Server answers Access-Request packets.
+type Server struct {
+	config     Config
+	now        func() time.Time
+	mu         sync.Mutex
+	challenges map[string]challenge
+	replies    map[string]reply // replies is keyed by source, Identifier and Request Authenticator.
+}
+
+// NewServer creates a RADIUS server based on the provided configuration.
+func NewServer(config Config) *Server {
+	if len(config.Secret) == 0 || config.Resolver == nil {
+		panic("radius requires a shared secret and a Resolver")
+	}
+	if config.ChallengeTimeout == 0 {
+		config.ChallengeTimeout = time.Minute
+	}
+	if config.ChallengeMessage == "" {
+		config.ChallengeMessage = "Enter your one-time password"
+	}
+	if config.DuplicateTimeout == 0 {
+		config.DuplicateTimeout = 5 * time.Second
+	}
+
+	return &Server{config: config, now: time.Now, challenges: map[string]challenge{}, replies: map[string]reply{}}
+}
+
+// Serve answers requests received on conn until it is closed.
+// Malformed or unauthenticated packets are silently discarded as required by RFC 2865.
+func (s *Server) Serve(conn net.PacketConn) error {
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		response, err := s.HandleFrom(addr, buf[:n])
+		if err != nil {
+			continue
+		}
+		if _, err := conn.WriteTo(response, addr); err != nil && errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+	}
+}
+
+// Handle processes a single Access-Request and returns the encoded response.
+// Requests are considered to come from the same source, see HandleFrom.
+func (s *Server) Handle(data []byte) ([]byte, error) {
+	return s.HandleFrom(nil, data)
+}
+
+// HandleFrom processes a single Access-Request received from source and
+// returns the encoded response. A retransmission, with the same source,
+// Identifier and Request Authenticator, is answered with the previous
+// response for DuplicateTimeout as required by RFC 2865 section 2.5, so the
+// OTP it carries is not validated a second time and rejected as replayed.
+func (s *Server) HandleFrom(source net.Addr, data []byte) ([]byte, error) {
+	request, err := ParsePacket(data)
+	if err != nil {
+		return nil, err
+	}
+	if request.Code != CodeAccessRequest {
+		return nil, errMalformedPacket
+	}
+	if _, ok := request.Get(AttributeMessageAuthenticator); !ok && s.config.RequireMessageAuthenticator {
+		return nil, errMalformedPacket
+	}
+	if !request.verifyMessageAuthenticator(s.config.Secret) {
+		return nil, errMalformedPacket
+	}
+
+	key := string(request.Identifier) + string(request.Authenticator[:])
+	if source != nil {
+		key = source.String() + "/" + key
+	}
+	if response, ok := s.duplicate(key); ok {
+		return response, nil
+	}
+
+	response, err := s.respond(request)
+	if err != nil {
+		return nil, err
+	}
+	s.remember(key, response)
+	return response, nil
+}
+
+// respond authenticates an Access-Request and encodes its response.
+func (s *Server) respond(request *Packet) ([]byte, error) {
+	user, hasUser := request.Get(AttributeUserName)
+	hidden, hasPassword := request.Get(AttributeUserPassword)
+	if !hasUser || !hasPassword {
+		return request.Response(CodeAccessReject).Sign(request, s.config.Secret)
+	}
+
+	password, err := DecryptPassword(hidden, s.config.Secret, request.Authenticator)
+	if err != nil {
+		return nil, err
+	}
+
+	code, err := s.authenticate(request, string(user), string(password))
+	if err != nil {
+		return nil, err
+	}
+
+	response := request.Response(code)
+	if code == CodeAccessChallenge {
+		state, err := s.newChallenge(string(user))
+		if err != nil {
+			return nil, err
+		}
+		response.Add(AttributeState, state)
+		response.Add(AttributeReplyMessage, []byte(s.config.ChallengeMessage))
+	}
+	return response.Sign(request, s.config.Secret)
+}
+
+// duplicate returns the response already sent to the request with key, if it has not expired.
+func (s *Server) duplicate(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.replies[key]
+	if !ok || s.now().After(r.expires) {
+		return nil, false
+	}
+	return r.data, true
+}
+
+// remember records the response to the request with key.
+func (s *Server) remember(key string, response []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for k, r := range s.replies {
+		if now.After(r.expires) {
+			delete(s.replies, k)
+		}
+	}
+	s.replies[key] = reply{data: response, expires: now.Add(s.config.DuplicateTimeout)}
+}
+
+// authenticate decides the response code of an Access-Request.
+func (s *Server) authenticate(request *Packet, user string, password string) (Code, error) {
+	if s.config.PasswordVerifier != nil {
+		state, ok := request.Get(AttributeState)
+		if !ok {
+			// First step, verify the password and challenge for the OTP
+			valid, err := s.config.PasswordVerifier(user, password)
+			if err != nil {
+				return 0, err
+			}
+			if !valid {
+				return CodeAccessReject, nil
+			}
+			return CodeAccessChallenge, nil
+		}
+
+		if !s.consumeChallenge(string(state), user) {
+			return CodeAccessReject, nil
+		}
+	}
+
+	validator, err := s.config.Resolver(user)
+	if err != nil {
+		return 0, err
+	}
+	if validator == nil {
+		return CodeAccessReject, nil
+	}
+
+	if _, err := validator.ValidateDetailed(password); err != nil {
+		return CodeAccessReject, nil
+	}
+	return CodeAccessAccept, nil
+}
+
+// newChallenge records a pending challenge for user and returns its State.
+func (s *Server) newChallenge(user string) ([]byte, error) {
+	state := make([]byte, 16)
+	if _, err := rand.Read(state); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for key, c := range s.challenges {
+		if now.After(c.expires) {
+			delete(s.challenges, key)
+		}
+	}
+	s.challenges[string(state)] = challenge{user: user, expires: now.Add(s.config.ChallengeTimeout)}
+	return state, nil
+}
+
+// consumeChallenge removes a challenge, reporting whether it was issued to user and has not expired.
+func (s *Server) consumeChallenge(state string, user string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.challenges[state]
+	delete(s.challenges, state)
+	return ok && c.user == user && !s.now().After(c.expires)
+}
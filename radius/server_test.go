@@ -0,0 +1,253 @@
+package radius_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sebastian-mora/basicOTP"
+	"github.com/sebastian-mora/basicOTP/radius"
+)
+
+var sharedSecret = []byte("xyzzy5461")
+
+// startServer serves config on a local UDP socket and returns a connected client.
+func startServer(t *testing.T, config radius.Config) net.Conn {
+	t.Helper()
+	config.Secret = sharedSecret
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go radius.NewServer(config).Serve(conn)
+	t.Cleanup(func() { conn.Close() })
+
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// exchange sends an Access-Request and returns the verified response.
+func exchange(t *testing.T, client net.Conn, user string, password string, state []byte, messageAuthenticator bool) *radius.Packet {
+	t.Helper()
+	request := &radius.Packet{Code: radius.CodeAccessRequest, Identifier: 7}
+	rand.Read(request.Authenticator[:])
+	request.Add(radius.AttributeUserName, []byte(user))
+	request.Add(radius.AttributeUserPassword, radius.EncryptPassword([]byte(password), sharedSecret, request.Authenticator))
+	if state != nil {
+		request.Add(radius.AttributeState, state)
+	}
+
+	if messageAuthenticator {
+		if err := request.AddMessageAuthenticator(sharedSecret); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := request.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.Write(data); err != nil {
+		t.Fatal(err)
+	}
+
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 4096)
+	n, err := client.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !radius.VerifyResponse(buf[:n], request, sharedSecret) {
+		t.Fatal("Response authenticator is invalid")
+	}
+
+	response, err := radius.ParsePacket(buf[:n])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Identifier != request.Identifier {
+		t.Errorf("Expected identifier %d, Got: %d", request.Identifier, response.Identifier)
+	}
+	return response
+}
+
+// newResolver returns a resolver for a single HOTP user "carol" at counter 0
+// of the RFC 4226 test data.
+func newResolver() func(string) (basicOTP.Validator, error) {
+	hotp := basicOTP.NewHTOP(basicOTP.HOTPConfig{Secret: []byte("12345678901234567890"), SynchronizationLimit: 5})
+	return func(user string) (basicOTP.Validator, error) {
+		if user != "carol" {
+			return nil, nil
+		}
+		return hotp, nil
+	}
+}
+
+func TestRADIUSSingleStep(t *testing.T) {
+	client := startServer(t, radius.Config{Resolver: newResolver()})
+
+	if response := exchange(t, client, "carol", "755224", nil, false); response.Code != radius.CodeAccessAccept {
+		t.Errorf("Expected Access-Accept, Got: %d", response.Code)
+	}
+
+	// Replayed code
+	if response := exchange(t, client, "carol", "755224", nil, false); response.Code != radius.CodeAccessReject {
+		t.Errorf("Expected Access-Reject, Got: %d", response.Code)
+	}
+
+	// Unknown user
+	if response := exchange(t, client, "mallory", "287082", nil, false); response.Code != radius.CodeAccessReject {
+		t.Errorf("Expected Access-Reject, Got: %d", response.Code)
+	}
+
+	// Message-Authenticator is verified and returned
+	response := exchange(t, client, "carol", "287082", nil, true)
+	if response.Code != radius.CodeAccessAccept {
+		t.Errorf("Expected Access-Accept, Got: %d", response.Code)
+	}
+	if _, ok := response.Get(radius.AttributeMessageAuthenticator); !ok {
+		t.Error("Response has no Message-Authenticator")
+	}
+}
+
+func TestRADIUSChallenge(t *testing.T) {
+	client := startServer(t, radius.Config{
+		Resolver: newResolver(),
+		PasswordVerifier: func(user string, password string) (bool, error) {
+			return user == "carol" && password == "hunter2", nil
+		},
+	})
+
+	if response := exchange(t, client, "carol", "wrong", nil, false); response.Code != radius.CodeAccessReject {
+		t.Errorf("Expected Access-Reject for a wrong password, Got: %d", response.Code)
+	}
+
+	response := exchange(t, client, "carol", "hunter2", nil, false)
+	if response.Code != radius.CodeAccessChallenge {
+		t.Fatalf("Expected Access-Challenge, Got: %d", response.Code)
+	}
+	state, ok := response.Get(radius.AttributeState)
+	if !ok {
+		t.Fatal("Challenge has no State")
+	}
+	if message, _ := response.Get(radius.AttributeReplyMessage); len(message) == 0 {
+		t.Error("Challenge has no Reply-Message")
+	}
+
+	// The OTP alone is not enough without a challenge
+	if response := exchange(t, client, "carol", "755224", []byte("forged-state"), false); response.Code != radius.CodeAccessReject {
+		t.Errorf("Expected Access-Reject for a forged State, Got: %d", response.Code)
+	}
+
+	if response := exchange(t, client, "carol", "755224", state, false); response.Code != radius.CodeAccessAccept {
+		t.Errorf("Expected Access-Accept, Got: %d", response.Code)
+	}
+
+	// A State can only be used once
+	if response := exchange(t, client, "carol", "287082", state, false); response.Code != radius.CodeAccessReject {
+		t.Errorf("Expected Access-Reject for a reused State, Got: %d", response.Code)
+	}
+}
+
+func TestPasswordEncryption(t *testing.T) {
+	var authenticator [16]byte
+	rand.Read(authenticator[:])
+
+	for _, password := range []string{"a", "123456", "exactly16bytes!!", "a password longer than sixteen bytes"} {
+		hidden := radius.EncryptPassword([]byte(password), sharedSecret, authenticator)
+		if len(hidden)%16 != 0 || bytes.Contains(hidden, []byte(password)) {
+			t.Errorf("Password %q was not hidden", password)
+		}
+
+		decrypted, err := radius.DecryptPassword(hidden, sharedSecret, authenticator)
+		if err != nil || string(decrypted) != password {
+			t.Errorf("Expected %q, Got: %q, %v", password, decrypted, err)
+		}
+	}
+}
+
+func TestHandleDiscardsInvalidPackets(t *testing.T) {
+	server := radius.NewServer(radius.Config{Secret: sharedSecret, Resolver: newResolver()})
+
+	accept := &radius.Packet{Code: radius.CodeAccessAccept, Identifier: 1}
+	data, _ := accept.Marshal()
+
+	for _, packet := range [][]byte{nil, []byte("short"), data} {
+		if _, err := server.Handle(packet); err == nil {
+			t.Errorf("Expected packet %x to be discarded", packet)
+		}
+	}
+}
+
+// accessRequest encodes an Access-Request for user and password.
+func accessRequest(t *testing.T, user string, password string, messageAuthenticator bool) []byte {
+	t.Helper()
+	request := &radius.Packet{Code: radius.CodeAccessRequest, Identifier: 9}
+	rand.Read(request.Authenticator[:])
+	request.Add(radius.AttributeUserName, []byte(user))
+	request.Add(radius.AttributeUserPassword, radius.EncryptPassword([]byte(password), sharedSecret, request.Authenticator))
+	if messageAuthenticator {
+		if err := request.AddMessageAuthenticator(sharedSecret); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := request.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestHandleRetransmission(t *testing.T) {
+	server := radius.NewServer(radius.Config{Secret: sharedSecret, Resolver: newResolver()})
+	nas1 := &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 1812}
+	nas2 := &net.UDPAddr{IP: net.IPv4(192, 0, 2, 2), Port: 1812}
+
+	data := accessRequest(t, "carol", "755224", false)
+	first, err := server.HandleFrom(nas1, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response, _ := radius.ParsePacket(first); response.Code != radius.CodeAccessAccept {
+		t.Fatalf("Expected Access-Accept, Got: %d", response.Code)
+	}
+
+	// The retransmission gets the same response instead of a replay rejection
+	if retransmitted, err := server.HandleFrom(nas1, data); err != nil || !bytes.Equal(retransmitted, first) {
+		t.Errorf("Expected the cached response, Got: %x, %v", retransmitted, err)
+	}
+
+	// The same packet from another source is a new request
+	other, err := server.HandleFrom(nas2, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response, _ := radius.ParsePacket(other); response.Code != radius.CodeAccessReject {
+		t.Errorf("Expected Access-Reject for a replayed code, Got: %d", response.Code)
+	}
+}
+
+func TestRequireMessageAuthenticator(t *testing.T) {
+	server := radius.NewServer(radius.Config{Secret: sharedSecret, Resolver: newResolver(), RequireMessageAuthenticator: true})
+
+	if _, err := server.Handle(accessRequest(t, "carol", "755224", false)); err == nil {
+		t.Error("Expected request without Message-Authenticator to be discarded")
+	}
+
+	data, err := server.Handle(accessRequest(t, "carol", "755224", true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response, _ := radius.ParsePacket(data); response.Code != radius.CodeAccessAccept {
+		t.Errorf("Expected Access-Accept, Got: %d", response.Code)
+	}
+}
@@ -0,0 +1,169 @@
+package basicOTP
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"math/big"
+	"strings"
+	"unicode"
+
+	"github.com/sebastian-mora/basicOTP/internal/kdf"
+)
+
+// DefaultRecoveryAlphabet leaves out characters that are easily confused, such as 0/O and 1/I.
+const DefaultRecoveryAlphabet = "23456789ABCDEFGHJKLMNPQRSTUVWXYZ"
+
+// RecoveryCodeConfig holds configuration parameters for recovery code generation.
+type RecoveryCodeConfig struct {
+	Count      int    // Count is the number of codes to generate, defaults to 10.
+	Length     int    // Length is the number of characters in a code, defaults to 10.
+	Alphabet   string // Alphabet holds the characters codes are drawn from, defaults to DefaultRecoveryAlphabet.
+	GroupSize  int    // GroupSize splits codes into groups of this many characters, 0 disables grouping.
+	Separator  string // Separator is placed between groups, defaults to "-".
+	Iterations int    // Iterations is the PBKDF2-HMAC-SHA256 iteration count, defaults to 10000.
+}
+
+// RecoveryHash is the salted hash of a single recovery code.
+type RecoveryHash struct {
+	Salt []byte `json:"salt"`
+	Hash []byte `json:"hash"`
+}
+
+// RecoveryCodes holds the hashes of unused single-use recovery codes.
+// Only the hashes are kept, it can be serialized to JSON and stored with the user.
+type RecoveryCodes struct {
+	Iterations    int            `json:"iterations"`               // Iterations is the PBKDF2 iteration count used for the hashes.
+	Separator     string         `json:"separator"`                // Separator is removed from codes before they are hashed.
+	CaseSensitive bool           `json:"case_sensitive,omitempty"` // CaseSensitive is set when the alphabet has characters differing only in case, otherwise codes are hashed in upper case.
+	Hashes        []RecoveryHash `json:"hashes"`                   // Hashes are the unused codes.
+}
+
+// GenerateRecoveryCodes creates random recovery codes based on the provided configuration.
+// The plaintext codes are returned to be shown to the user once,
+// the RecoveryCodes only hold their salted hashes.
+func GenerateRecoveryCodes(config RecoveryCodeConfig) ([]string, *RecoveryCodes, error) {
+	if config.Count < 0 || config.Length < 0 || config.Iterations < 0 {
+		return nil, nil, errors.New("basicOTP: recovery code count, length and iterations must not be negative")
+	}
+	if config.Count == 0 {
+		config.Count = 10
+	}
+	if config.Length == 0 {
+		config.Length = 10
+	}
+	if config.Alphabet == "" {
+		config.Alphabet = DefaultRecoveryAlphabet
+	}
+	if config.Separator == "" {
+		config.Separator = "-"
+	}
+	if config.Iterations == 0 {
+		config.Iterations = 10000
+	}
+	if len(config.Alphabet) < 2 || strings.Contains(config.Alphabet, config.Separator) {
+		return nil, nil, errors.New("basicOTP: recovery alphabet needs two characters and must not contain the separator")
+	}
+
+	recovery := &RecoveryCodes{Iterations: config.Iterations, Separator: config.Separator, CaseSensitive: !foldsUniquely(config.Alphabet)}
+	codes := make([]string, 0, config.Count)
+	for i := 0; i < config.Count; i++ {
+		code, err := randomCode(config.Alphabet, config.Length)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, nil, err
+		}
+
+		recovery.Hashes = append(recovery.Hashes, RecoveryHash{Salt: salt, Hash: recovery.hash(recovery.normalize(code), salt)})
+		codes = append(codes, groupCode(code, config.GroupSize, config.Separator))
+	}
+	return codes, recovery, nil
+}
+
+// Verify checks code against every unused recovery code in constant time
+// and consumes it if it matches. Separators and whitespace are ignored, and
+// so is case unless the alphabet needs it.
+func (r *RecoveryCodes) Verify(code string) bool {
+	code = r.normalize(code)
+
+	match := -1
+	for i, stored := range r.Hashes {
+		equal := subtle.ConstantTimeCompare(r.hash(code, stored.Salt), stored.Hash)
+		match = subtle.ConstantTimeSelect(equal, i, match)
+	}
+
+	if match < 0 {
+		return false
+	}
+	r.Hashes = append(r.Hashes[:match:match], r.Hashes[match+1:]...)
+	return true
+}
+
+// Remaining returns the number of unused recovery codes.
+func (r *RecoveryCodes) Remaining() int {
+	return len(r.Hashes)
+}
+
+// normalize removes separators and whitespace from code and upper cases it
+// unless the codes are case sensitive.
+func (r *RecoveryCodes) normalize(code string) string {
+	code = strings.Join(strings.Fields(code), "")
+	if r.Separator != "" {
+		code = strings.ReplaceAll(code, r.Separator, "")
+	}
+	if !r.CaseSensitive {
+		code = strings.ToUpper(code)
+	}
+	return code
+}
+
+// hash derives the stored hash of a code without separators.
+func (r *RecoveryCodes) hash(code string, salt []byte) []byte {
+	return kdf.PBKDF2([]byte(code), salt, r.Iterations, sha256.Size, sha256.New)
+}
+
+// randomCode draws length characters uniformly from alphabet.
+func randomCode(alphabet string, length int) (string, error) {
+	max := big.NewInt(int64(len(alphabet)))
+	code := make([]byte, length)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = alphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// foldsUniquely reports whether no two characters of alphabet differ only in case.
+func foldsUniquely(alphabet string) bool {
+	seen := map[rune]bool{}
+	for _, c := range alphabet {
+		upper := unicode.ToUpper(c)
+		if seen[upper] {
+			return false
+		}
+		seen[upper] = true
+	}
+	return true
+}
+
+// groupCode splits code into groups of size characters joined by separator.
+func groupCode(code string, size int, separator string) string {
+	if size <= 0 || size >= len(code) {
+		return code
+	}
+
+	groups := make([]string, 0, (len(code)+size-1)/size)
+	for len(code) > size {
+		groups = append(groups, code[:size])
+		code = code[size:]
+	}
+	return strings.Join(append(groups, code), separator)
+}
@@ -0,0 +1,114 @@
+package basicOTP_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/sebastian-mora/basicOTP"
+)
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, recovery, err := basicOTP.GenerateRecoveryCodes(basicOTP.RecoveryCodeConfig{
+		Count:      5,
+		Length:     12,
+		Alphabet:   "0123456789",
+		GroupSize:  4,
+		Iterations: 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(codes) != 5 || recovery.Remaining() != 5 {
+		t.Fatalf("Expected 5 codes, Got: %d codes, %d remaining", len(codes), recovery.Remaining())
+	}
+
+	for _, code := range codes {
+		groups := strings.Split(code, "-")
+		if len(groups) != 3 || len(groups[0]) != 4 || strings.Trim(strings.Join(groups, ""), "0123456789") != "" {
+			t.Errorf("Unexpected code format: %s", code)
+		}
+	}
+
+	// Only hashes are stored
+	stored, _ := json.Marshal(recovery)
+	for _, code := range codes {
+		if strings.Contains(string(stored), strings.ReplaceAll(code, "-", "")) {
+			t.Errorf("Stored recovery codes contain plaintext code %s", code)
+		}
+	}
+}
+
+func TestRecoveryCodesVerify(t *testing.T) {
+	codes, recovery, err := basicOTP.GenerateRecoveryCodes(basicOTP.RecoveryCodeConfig{Count: 3, GroupSize: 5, Iterations: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if recovery.Verify("WRONG-CODE0") {
+		t.Error("Unknown code was accepted")
+	}
+
+	// Separators are optional when entering a code
+	if !recovery.Verify(strings.ReplaceAll(codes[1], "-", "")) {
+		t.Error("Valid code was rejected")
+	}
+	if recovery.Verify(codes[1]) {
+		t.Error("Code was accepted twice")
+	}
+	if recovery.Remaining() != 2 {
+		t.Errorf("Expected 2 remaining codes, Got: %d", recovery.Remaining())
+	}
+
+	// State survives serialization
+	data, err := json.Marshal(recovery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var restored basicOTP.RecoveryCodes
+	if err := json.Unmarshal(data, &restored); err != nil {
+		t.Fatal(err)
+	}
+
+	if !restored.Verify(" "+codes[0]+" ") || !restored.Verify(codes[2]) || restored.Remaining() != 0 {
+		t.Error("Restored codes did not verify")
+	}
+}
+
+func TestRecoveryCodesNormalization(t *testing.T) {
+	codes, recovery, err := basicOTP.GenerateRecoveryCodes(basicOTP.RecoveryCodeConfig{Count: 2, GroupSize: 5, Iterations: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Case and whitespace within the code are ignored
+	if !recovery.Verify(strings.ToLower(strings.ReplaceAll(codes[0], "-", " "))) {
+		t.Error("Lower case code with spaces was rejected")
+	}
+
+	// An alphabet with characters differing only in case is case sensitive
+	codes, recovery, err = basicOTP.GenerateRecoveryCodes(basicOTP.RecoveryCodeConfig{Count: 1, Length: 16, Alphabet: "aA", Iterations: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recovery.Verify(strings.ToUpper(codes[0])) && strings.ToUpper(codes[0]) != codes[0] {
+		t.Error("Case sensitive code was accepted in upper case")
+	}
+	if !recovery.Verify(codes[0]) {
+		t.Error("Case sensitive code was rejected")
+	}
+}
+
+func TestRecoveryCodesInvalidConfig(t *testing.T) {
+	for _, config := range []basicOTP.RecoveryCodeConfig{
+		{Alphabet: "AB-"},
+		{Count: -1},
+		{Length: -1},
+		{Iterations: -1},
+	} {
+		if _, _, err := basicOTP.GenerateRecoveryCodes(config); err == nil {
+			t.Errorf("Expected an error for %+v", config)
+		}
+	}
+}
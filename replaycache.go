@@ -0,0 +1,113 @@
+package basicOTP
+
+import (
+	"container/list"
+	"context"
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ReplayCache records the time steps used by the TOTP codes of each credential,
+// so a code accepted by one node is rejected as replayed by every other node
+// sharing the cache. Entries only need to be kept for their TTL, after which
+// the time step is outside the validation window.
+type ReplayCache interface {
+	// Use marks step of the credential id as used for ttl. It reports false if
+	// the step was already used, the check and the update must be atomic.
+	Use(ctx context.Context, id string, step int, ttl time.Duration) (bool, error)
+}
+
+// MemoryReplayCacheConfig holds configuration parameters for a MemoryReplayCache.
+type MemoryReplayCacheConfig struct {
+	Shards   int              // Shards is the number of independently locked shards, defaults to 16.
+	Capacity int              // Capacity is the number of entries kept per shard, defaults to 4096.
+	Now      func() time.Time // Now returns the current time, defaults to time.Now.
+}
+
+// MemoryReplayCache is a ReplayCache kept in memory, for a single node or tests.
+// Each shard is a LRU list, when a shard is full the least recently used entry
+// is dropped even if it has not expired, so size Capacity for the number of
+// codes accepted per shard within the longest TTL.
+type MemoryReplayCache struct {
+	shards []*replayShard
+	now    func() time.Time
+}
+
+// replayShard is a LRU list of used time steps and their expiry.
+type replayShard struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	order    *list.List // order holds replayEntry values, most recently used first.
+}
+
+type replayEntry struct {
+	key       string
+	expiresAt time.Time
+}
+
+// NewMemoryReplayCache creates a MemoryReplayCache based on the provided configuration.
+func NewMemoryReplayCache(config MemoryReplayCacheConfig) *MemoryReplayCache {
+	if config.Shards <= 0 {
+		config.Shards = 16
+	}
+	if config.Capacity <= 0 {
+		config.Capacity = 4096
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+
+	shards := make([]*replayShard, config.Shards)
+	for i := range shards {
+		shards[i] = &replayShard{capacity: config.Capacity, entries: map[string]*list.Element{}, order: list.New()}
+	}
+	return &MemoryReplayCache{shards: shards, now: config.Now}
+}
+
+// Use marks step of the credential id as used for ttl.
+func (c *MemoryReplayCache) Use(ctx context.Context, id string, step int, ttl time.Duration) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	key := id + ":" + strconv.Itoa(step)
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	shard := c.shards[h.Sum32()%uint32(len(c.shards))]
+	now := c.now()
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if element, ok := shard.entries[key]; ok {
+		entry := element.Value.(*replayEntry)
+		shard.order.MoveToFront(element)
+		if now.Before(entry.expiresAt) {
+			return false, nil
+		}
+		entry.expiresAt = now.Add(ttl)
+		return true, nil
+	}
+
+	shard.entries[key] = shard.order.PushFront(&replayEntry{key: key, expiresAt: now.Add(ttl)})
+	if shard.order.Len() > shard.capacity {
+		oldest := shard.order.Back()
+		shard.order.Remove(oldest)
+		delete(shard.entries, oldest.Value.(*replayEntry).key)
+	}
+	return true, nil
+}
+
+// Len returns the number of entries in the cache, including expired ones not yet dropped.
+func (c *MemoryReplayCache) Len() int {
+	n := 0
+	for _, shard := range c.shards {
+		shard.mu.Lock()
+		n += shard.order.Len()
+		shard.mu.Unlock()
+	}
+	return n
+}
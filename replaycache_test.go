@@ -0,0 +1,81 @@
+package basicOTP_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sebastian-mora/basicOTP"
+)
+
+func TestMemoryReplayCache(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1706984520, 0)
+	cache := basicOTP.NewMemoryReplayCache(basicOTP.MemoryReplayCacheConfig{Shards: 1, Capacity: 2, Now: func() time.Time { return now }})
+
+	for _, tc := range []struct {
+		id       string
+		step     int
+		expected bool
+	}{
+		{"alice", 1, true},
+		{"bob", 1, true},
+		{"alice", 1, false},
+		{"alice", 2, true}, // drops bob:1, the least recently used
+		{"alice", 1, false},
+		{"bob", 1, true},
+	} {
+		unused, err := cache.Use(ctx, tc.id, tc.step, time.Minute)
+		if err != nil || unused != tc.expected {
+			t.Errorf("Use(%s, %d): expected %v, got %v, %v", tc.id, tc.step, tc.expected, unused, err)
+		}
+	}
+	if cache.Len() != 2 {
+		t.Errorf("Expected capacity to be enforced, got %d entries", cache.Len())
+	}
+
+	now = now.Add(time.Minute)
+	if unused, _ := cache.Use(ctx, "bob", 1, time.Minute); !unused {
+		t.Error("Expected expired entry to be unused")
+	}
+}
+
+// failingCache is a ReplayCache that is unavailable.
+type failingCache struct{}
+
+func (failingCache) Use(ctx context.Context, id string, step int, ttl time.Duration) (bool, error) {
+	return false, errors.New("unavailable")
+}
+
+func TestTOTPReplayCache(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	cache := basicOTP.NewMemoryReplayCache(basicOTP.MemoryReplayCacheConfig{})
+	node1 := basicOTP.NewTOTP(basicOTP.TOTPConfig{Secret: secret, Window: 1, ID: "alice", ReplayCache: cache})
+	node2 := basicOTP.NewTOTP(basicOTP.TOTPConfig{Secret: secret, Window: 1, ID: "alice", ReplayCache: cache})
+	app := basicOTP.NewTOTP(basicOTP.TOTPConfig{Secret: secret})
+
+	code := app.GenerateAt(1706984520)
+	if !node1.ValidateAt(1706984520, code) {
+		t.Fatal("Expected code to be accepted")
+	}
+	if _, err := node2.ValidateDetailedAt(1706984520, code); !errors.Is(err, basicOTP.ErrReplayedCode) {
+		t.Errorf("Expected code accepted by another node to be replayed, got %v", err)
+	}
+	if !node2.ValidateAt(1706984520, app.GenerateAt(1706984520+30)) {
+		t.Error("Expected the next time step to be accepted")
+	}
+
+	// An unavailable cache rejects the code without consuming it locally.
+	down := basicOTP.NewTOTP(basicOTP.TOTPConfig{Secret: secret, ID: "alice", ReplayCache: failingCache{}})
+	if result, err := down.ValidateDetailedAt(1706984520, code); err == nil || result.Valid || down.State().LastStep != -1 {
+		t.Errorf("Expected cache error, got %+v, %v", result, err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic for a replay cache without ID")
+		}
+	}()
+	basicOTP.NewTOTP(basicOTP.TOTPConfig{Secret: secret, ReplayCache: cache})
+}
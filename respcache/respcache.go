@@ -0,0 +1,281 @@
+// Package respcache implements basicOTP.ReplayCache on a Redis compatible
+// server, speaking the RESP protocol with the standard library only.
+//
+// A used time step is stored with "SET key 1 NX PX ttl", which atomically
+// reports whether the key already existed and lets the server expire it.
+package respcache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// Error is an error reply of the server, such as "WRONGPASS invalid username-password pair".
+type Error string
+
+// Error implements the error interface.
+func (e Error) Error() string {
+	return "respcache: " + string(e)
+}
+
+var errProtocol = errors.New("respcache: invalid reply")
+
+// Limits on replies, far above anything a replay check needs, so a
+// misbehaving server can not make the client allocate without bound.
+const (
+	maxBulkLength  = 1 << 20
+	maxArrayLength = 1 << 10
+	maxArrayDepth  = 4
+)
+
+// DialFunc opens a connection to addr, like net.Dialer.DialContext.
+type DialFunc func(ctx context.Context, network string, addr string) (net.Conn, error)
+
+// Config holds configuration parameters for a Cache.
+type Config struct {
+	Addr     string        // Addr is the host and port of the server.
+	Password string        // Password is sent with AUTH when connecting, if set.
+	DB       int           // DB is the database selected when connecting.
+	Prefix   string        // Prefix is prepended to the keys, defaults to "basicotp:replay:".
+	MaxIdle  int           // MaxIdle is the number of idle connections kept open, defaults to 4.
+	Timeout  time.Duration // Timeout bounds each command when the context has no earlier deadline, defaults to 5 seconds.
+	Dial     DialFunc      // Dial opens connections, defaults to a net.Dialer.
+}
+
+// Cache is a basicOTP.ReplayCache stored on a Redis compatible server.
+// It is safe for concurrent use.
+type Cache struct {
+	config Config
+	idle   chan *conn
+}
+
+// conn is a connection to the server.
+type conn struct {
+	net.Conn
+	r       *bufio.Reader
+	timeout time.Duration
+}
+
+// New creates a Cache based on the provided configuration. Connections are
+// opened when needed.
+func New(config Config) *Cache {
+	if config.Addr == "" {
+		panic("respcache requires an Addr")
+	}
+	if config.Prefix == "" {
+		config.Prefix = "basicotp:replay:"
+	}
+	if config.MaxIdle <= 0 {
+		config.MaxIdle = 4
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 5 * time.Second
+	}
+	if config.Dial == nil {
+		config.Dial = (&net.Dialer{Timeout: config.Timeout}).DialContext
+	}
+
+	return &Cache{config: config, idle: make(chan *conn, config.MaxIdle)}
+}
+
+// Use marks step of the credential id as used for ttl, reporting false if it already was.
+func (c *Cache) Use(ctx context.Context, id string, step int, ttl time.Duration) (bool, error) {
+	ms := ttl.Milliseconds()
+	if ms < 1 {
+		ms = 1
+	}
+	key := c.config.Prefix + id + ":" + strconv.Itoa(step)
+
+	reply, err := c.do(ctx, "SET", key, "1", "NX", "PX", strconv.FormatInt(ms, 10))
+	if err != nil {
+		return false, err
+	}
+	switch reply {
+	case "OK":
+		return true, nil
+	case nil:
+		return false, nil
+	default:
+		return false, errProtocol
+	}
+}
+
+// Close closes the idle connections.
+func (c *Cache) Close() error {
+	for {
+		select {
+		case cn := <-c.idle:
+			cn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// do sends a command on a pooled connection and returns its reply. A
+// connection is only reused if the exchange completed.
+func (c *Cache) do(ctx context.Context, args ...string) (any, error) {
+	cn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := cn.do(ctx, args...)
+	var replyErr Error
+	if err != nil && !errors.As(err, &replyErr) {
+		cn.Close()
+		return nil, err
+	}
+
+	select {
+	case c.idle <- cn:
+	default:
+		cn.Close()
+	}
+	return reply, err
+}
+
+// get returns an idle connection or opens a new one.
+func (c *Cache) get(ctx context.Context) (*conn, error) {
+	select {
+	case cn := <-c.idle:
+		return cn, nil
+	default:
+	}
+
+	netConn, err := c.config.Dial(ctx, "tcp", c.config.Addr)
+	if err != nil {
+		return nil, err
+	}
+	cn := &conn{Conn: netConn, r: bufio.NewReader(netConn), timeout: c.config.Timeout}
+
+	if c.config.Password != "" {
+		if _, err := cn.do(ctx, "AUTH", c.config.Password); err != nil {
+			cn.Close()
+			return nil, err
+		}
+	}
+	if c.config.DB != 0 {
+		if _, err := cn.do(ctx, "SELECT", strconv.Itoa(c.config.DB)); err != nil {
+			cn.Close()
+			return nil, err
+		}
+	}
+	return cn, nil
+}
+
+// do writes a command as an array of bulk strings and reads the reply. It
+// is bounded by the timeout or the earlier deadline of ctx and interrupted
+// when ctx is done, leaving the connection unusable.
+func (cn *conn) do(ctx context.Context, args ...string) (any, error) {
+	deadline := time.Now().Add(cn.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := cn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	// The watcher is waited for, so it can not interrupt the connection
+	// once it is back in the pool and used by another caller.
+	done, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			cn.SetDeadline(time.Unix(1, 0)) // unblock pending reads and writes
+		case <-done:
+		}
+	}()
+
+	reply, err := cn.exchange(args)
+	close(done)
+	<-stopped
+	if err != nil && ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return reply, err
+}
+
+// exchange writes the command and reads its reply.
+func (cn *conn) exchange(args []string) (any, error) {
+	command := fmt.Sprintf("*%d\r\n", len(args))
+	for _, arg := range args {
+		command += fmt.Sprintf("$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(cn, command); err != nil {
+		return nil, err
+	}
+	return readReply(cn.r, 0)
+}
+
+// readReply reads a reply: a simple string, error, integer, bulk string or
+// array nested depth arrays deep. A null bulk string or array is returned as nil.
+func readReply(r *bufio.Reader, depth int) (any, error) {
+	slice, err := r.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) {
+		return nil, errProtocol
+	}
+	if err != nil {
+		return nil, err
+	}
+	line := string(slice)
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, errProtocol
+	}
+	kind, payload := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return payload, nil
+	case '-':
+		return nil, Error(payload)
+	case ':':
+		n, err := strconv.ParseInt(payload, 10, 64)
+		if err != nil {
+			return nil, errProtocol
+		}
+		return n, nil
+	case '$':
+		n, err := strconv.Atoi(payload)
+		if err != nil || n < -1 || n > maxBulkLength {
+			return nil, errProtocol
+		}
+		if n == -1 {
+			return nil, nil
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return string(data[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(payload)
+		if err != nil || n < -1 || n > maxArrayLength || depth >= maxArrayDepth {
+			return nil, errProtocol
+		}
+		if n == -1 {
+			return nil, nil
+		}
+		items := make([]any, n)
+		for i := range items {
+			item, err := readReply(r, depth+1)
+			var replyErr Error
+			if errors.As(err, &replyErr) {
+				item = replyErr // keep reading, the error is an element of the array
+			} else if err != nil {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	default:
+		return nil, errProtocol
+	}
+}
@@ -0,0 +1,301 @@
+package respcache_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sebastian-mora/basicOTP"
+	"github.com/sebastian-mora/basicOTP/respcache"
+)
+
+// fakeServer is a local RESP server implementing AUTH, SELECT and SET with NX and PX.
+type fakeServer struct {
+	listener net.Listener
+	password string
+
+	mu       sync.Mutex
+	now      time.Time
+	keys     map[string]time.Time // keys maps a "db/key" to its expiry.
+	commands []string
+	conns    int
+}
+
+func newFakeServer(t *testing.T, password string) *fakeServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	s := &fakeServer{listener: listener, password: password, now: time.Unix(1706984520, 0), keys: map[string]time.Time{}}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns++
+			s.mu.Unlock()
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeServer) addr() string {
+	return s.listener.Addr().String()
+}
+
+// advance moves the clock of the server forward.
+func (s *fakeServer) advance(d time.Duration) {
+	s.mu.Lock()
+	s.now = s.now.Add(d)
+	s.mu.Unlock()
+}
+
+func (s *fakeServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	authenticated := s.password == ""
+	db := "0"
+
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.commands = append(s.commands, strings.Join(args, " "))
+		reply := "-ERR unknown command\r\n"
+		switch {
+		case strings.EqualFold(args[0], "AUTH") && len(args) == 2:
+			if args[1] == s.password {
+				authenticated = true
+				reply = "+OK\r\n"
+			} else {
+				reply = "-WRONGPASS invalid username-password pair\r\n"
+			}
+		case !authenticated:
+			reply = "-NOAUTH Authentication required.\r\n"
+		case strings.EqualFold(args[0], "SELECT") && len(args) == 2:
+			db = args[1]
+			reply = "+OK\r\n"
+		case strings.EqualFold(args[0], "SET") && len(args) == 6:
+			ms, _ := strconv.Atoi(args[5])
+			key := db + "/" + args[1]
+			if expiry, ok := s.keys[key]; ok && s.now.Before(expiry) {
+				reply = "$-1\r\n"
+			} else {
+				s.keys[key] = s.now.Add(time.Duration(ms) * time.Millisecond)
+				reply = "+OK\r\n"
+			}
+		}
+		s.mu.Unlock()
+
+		if _, err := io.WriteString(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+// readCommand reads an array of bulk strings.
+func readCommand(r *bufio.Reader) ([]string, error) {
+	var n int
+	if _, err := fmt.Fscanf(r, "*%d\r\n", &n); err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		var size int
+		if _, err := fmt.Fscanf(r, "$%d\r\n", &size); err != nil {
+			return nil, err
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		args[i] = string(data[:size])
+	}
+	return args, nil
+}
+
+func TestUse(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeServer(t, "hunter2")
+	cache := respcache.New(respcache.Config{Addr: server.addr(), Password: "hunter2", DB: 2})
+	defer cache.Close()
+
+	for _, tc := range []struct {
+		id       string
+		step     int
+		expected bool
+	}{
+		{"alice", 56899484, true},
+		{"alice", 56899484, false},
+		{"alice", 56899485, true},
+		{"bob", 56899484, true},
+	} {
+		unused, err := cache.Use(ctx, tc.id, tc.step, 90*time.Second)
+		if err != nil || unused != tc.expected {
+			t.Errorf("Use(%s, %d): expected %v, got %v, %v", tc.id, tc.step, tc.expected, unused, err)
+		}
+	}
+
+	// The server expires the key after the TTL.
+	server.advance(90 * time.Second)
+	if unused, err := cache.Use(ctx, "alice", 56899484, 90*time.Second); err != nil || !unused {
+		t.Errorf("Expected expired step to be unused, got %v, %v", unused, err)
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.conns != 1 {
+		t.Errorf("Expected the connection to be reused, got %d connections", server.conns)
+	}
+	expected := []string{"AUTH hunter2", "SELECT 2", "SET basicotp:replay:alice:56899484 1 NX PX 90000"}
+	for i, command := range expected {
+		if server.commands[i] != command {
+			t.Errorf("Expected command %q, got %q", command, server.commands[i])
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeServer(t, "hunter2")
+
+	cache := respcache.New(respcache.Config{Addr: server.addr(), Password: "wrong"})
+	var replyErr respcache.Error
+	if _, err := cache.Use(ctx, "alice", 1, time.Minute); !errors.As(err, &replyErr) || !strings.HasPrefix(string(replyErr), "WRONGPASS") {
+		t.Errorf("Expected WRONGPASS error, got %v", err)
+	}
+
+	cache = respcache.New(respcache.Config{Addr: server.addr()})
+	if _, err := cache.Use(ctx, "alice", 1, time.Minute); !errors.As(err, &replyErr) || !strings.HasPrefix(string(replyErr), "NOAUTH") {
+		t.Errorf("Expected NOAUTH error, got %v", err)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	cache = respcache.New(respcache.Config{Addr: server.addr(), Password: "hunter2"})
+	if _, err := cache.Use(canceled, "alice", 1, time.Minute); err == nil {
+		t.Error("Expected error for canceled context")
+	}
+}
+
+func TestTOTPNodes(t *testing.T) {
+	server := newFakeServer(t, "")
+	secret := []byte("12345678901234567890")
+	node := func() *basicOTP.TOTP {
+		cache := respcache.New(respcache.Config{Addr: server.addr()})
+		t.Cleanup(func() { cache.Close() })
+		return basicOTP.NewTOTP(basicOTP.TOTPConfig{Secret: secret, Window: 1, ID: "alice", ReplayCache: cache})
+	}
+	node1, node2 := node(), node()
+
+	code := basicOTP.NewTOTP(basicOTP.TOTPConfig{Secret: secret}).GenerateAt(1706984520)
+	if _, err := node1.ValidateDetailedAt(1706984520, code); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := node2.ValidateDetailedAt(1706984520, code); !errors.Is(err, basicOTP.ErrReplayedCode) {
+		t.Errorf("Expected code accepted by another node to be replayed, got %v", err)
+	}
+}
+
+// newRawServer starts a server answering every connection with reply once
+// the first command arrives, or never if reply is empty.
+func newRawServer(t *testing.T, reply string) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var mu sync.Mutex
+	var conns []net.Conn
+	t.Cleanup(func() {
+		listener.Close()
+		mu.Lock()
+		defer mu.Unlock()
+		for _, conn := range conns {
+			conn.Close()
+		}
+	})
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			conns = append(conns, conn)
+			mu.Unlock()
+			go func() {
+				if _, err := readCommand(bufio.NewReader(conn)); err == nil && reply != "" {
+					io.WriteString(conn, reply)
+				}
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestStalledServer(t *testing.T) {
+	addr := newRawServer(t, "")
+
+	// A canceled context without deadline interrupts the blocked read.
+	cache := respcache.New(respcache.Config{Addr: addr})
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if _, err := cache.Use(ctx, "alice", 1, time.Minute); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected canceled, got %v", err)
+	}
+
+	// Without a context deadline the timeout applies.
+	cache = respcache.New(respcache.Config{Addr: addr, Timeout: 50 * time.Millisecond})
+	var netErr net.Error
+	if _, err := cache.Use(context.Background(), "alice", 1, time.Minute); !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("Expected timeout, got %v", err)
+	}
+}
+
+func TestOversizedReplies(t *testing.T) {
+	for _, reply := range []string{
+		"$1073741824\r\n",
+		"*1000000000\r\n",
+		"*1\r\n*1\r\n*1\r\n*1\r\n*1\r\n+OK\r\n",
+		"+" + strings.Repeat("a", 1<<16) + "\r\n",
+	} {
+		cache := respcache.New(respcache.Config{Addr: newRawServer(t, reply)})
+		if _, err := cache.Use(context.Background(), "alice", 1, time.Minute); err == nil || err.Error() != "respcache: invalid reply" {
+			t.Errorf("Expected invalid reply for %.20q, got %v", reply, err)
+		}
+	}
+}
+
+func TestCancelDoesNotLeakIntoPool(t *testing.T) {
+	server := newFakeServer(t, "")
+	cache := respcache.New(respcache.Config{Addr: server.addr(), MaxIdle: 1})
+	defer cache.Close()
+
+	// A cancellation racing the end of a command must not interrupt the
+	// pooled connection once the next caller uses it.
+	for i := 0; i < 200; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		go cancel()
+		cache.Use(ctx, "alice", 2*i, time.Minute)
+		if _, err := cache.Use(context.Background(), "alice", 2*i+1, time.Minute); err != nil {
+			t.Fatalf("Unexpected error after a canceled command: %v", err)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package basicOTP
+
+import (
+	"sync"
+	"time"
+)
+
+// RotationMatch reports which generator of a RotatingCredential accepted a code.
+type RotationMatch int
+
+const (
+	MatchNone RotationMatch = iota // MatchNone indicates the code was rejected.
+	MatchOld                       // MatchOld indicates the code was accepted by the old generator.
+	MatchNew                       // MatchNew indicates the code was accepted by the new generator.
+)
+
+// RotationResult describes the outcome of validating a code against a RotatingCredential.
+type RotationResult struct {
+	Match RotationMatch // Match is the generator that accepted the code.
+	ValidationResult
+}
+
+// RotationConfig holds configuration parameters for a RotatingCredential.
+type RotationConfig struct {
+	Old    Validator        // Old is the generator being replaced, such as a *TOTP from NewTOTP.
+	New    Validator        // New is the replacement generator.
+	Cutoff time.Time        // Cutoff is when codes of the old generator stop being accepted.
+	Now    func() time.Time // Now returns the current time, defaults to time.Now.
+}
+
+// RotatingCredential accepts codes of an old and a new generator while a user
+// re-keys their authenticator, for example after a suspected leak or to move
+// from SHA1 to SHA256. The old generator is retired at the cutoff or as soon
+// as a code of the new one is accepted, whichever comes first.
+//
+// It is safe for concurrent use, validations are serialized so the generators
+// are never used concurrently as long as they are only validated through it.
+type RotatingCredential struct {
+	mu     sync.Mutex
+	old    Validator
+	new    Validator
+	cutoff time.Time
+	now    func() time.Time
+}
+
+// NewRotatingCredential creates a RotatingCredential based on the provided configuration.
+func NewRotatingCredential(config RotationConfig) *RotatingCredential {
+	if config.Old == nil || config.New == nil {
+		panic("rotation requires an old and a new generator")
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+
+	return &RotatingCredential{
+		old:    config.Old,
+		new:    config.New,
+		cutoff: config.Cutoff,
+		now:    config.Now,
+	}
+}
+
+// ValidateRotation validates code against the new generator and, until it is
+// retired, the old one, reporting which one accepted it.
+func (r *RotatingCredential) ValidateRotation(code string) (RotationResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.old != nil && !r.now().Before(r.cutoff) {
+		r.old = nil
+	}
+
+	result, err := r.new.ValidateDetailed(code)
+	if err == nil {
+		r.old = nil
+		return RotationResult{Match: MatchNew, ValidationResult: result}, nil
+	}
+	if r.old == nil {
+		return RotationResult{ValidationResult: result}, err
+	}
+
+	oldResult, oldErr := r.old.ValidateDetailed(code)
+	if oldErr == nil {
+		return RotationResult{Match: MatchOld, ValidationResult: oldResult}, nil
+	}
+
+	// Report the more specific reason, the codes may have different lengths.
+	if oldResult.Reason == ReasonReplayedCode || result.Reason == ReasonMalformedCode {
+		return RotationResult{ValidationResult: oldResult}, oldErr
+	}
+	return RotationResult{ValidationResult: result}, err
+}
+
+// ValidateDetailed validates code like ValidateRotation, so a RotatingCredential
+// can be used wherever a Validator is expected.
+func (r *RotatingCredential) ValidateDetailed(code string) (ValidationResult, error) {
+	result, err := r.ValidateRotation(code)
+	return result.ValidationResult, err
+}
+
+// Validate reports whether code is accepted by either generator.
+func (r *RotatingCredential) Validate(code string) bool {
+	_, err := r.ValidateRotation(code)
+	return err == nil
+}
+
+// CodeLength returns the length of the codes of the new generator.
+func (r *RotatingCredential) CodeLength() int {
+	return r.new.CodeLength()
+}
+
+// Retired reports whether the old generator has been retired. Once it has,
+// only the new generator needs to be stored.
+func (r *RotatingCredential) Retired() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.old == nil || !r.now().Before(r.cutoff)
+}
+
+// New returns the new generator.
+func (r *RotatingCredential) New() Validator {
+	return r.new
+}
@@ -0,0 +1,111 @@
+package basicOTP_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sebastian-mora/basicOTP"
+)
+
+// newTestRotation rotates a SHA1 TOTP to a SHA256 TOTP with 8 digits and
+// returns the client generators of both.
+func newTestRotation(now *time.Time, cutoff time.Time) (*basicOTP.RotatingCredential, *basicOTP.TOTP, *basicOTP.TOTP) {
+	oldSecret, newSecret := []byte("12345678901234567890"), []byte("12345678901234567890123456789012")
+	rotation := basicOTP.NewRotatingCredential(basicOTP.RotationConfig{
+		Old:    basicOTP.NewTOTP(basicOTP.TOTPConfig{Secret: oldSecret, HashType: basicOTP.SHA1, Window: 1}),
+		New:    basicOTP.NewTOTP(basicOTP.TOTPConfig{Secret: newSecret, HashType: basicOTP.SHA256, CodeLength: 8, Window: 1}),
+		Cutoff: cutoff,
+		Now:    func() time.Time { return *now },
+	})
+	return rotation,
+		basicOTP.NewTOTP(basicOTP.TOTPConfig{Secret: oldSecret, HashType: basicOTP.SHA1}),
+		basicOTP.NewTOTP(basicOTP.TOTPConfig{Secret: newSecret, HashType: basicOTP.SHA256, CodeLength: 8})
+}
+
+func TestRotationRetiresOldOnNewUse(t *testing.T) {
+	now := time.Now()
+	rotation, oldApp, newApp := newTestRotation(&now, now.Add(24*time.Hour))
+
+	result, err := rotation.ValidateRotation(oldApp.Generate())
+	if err != nil || result.Match != basicOTP.MatchOld || rotation.Retired() {
+		t.Fatalf("Expected old code to be accepted during the grace period, got %+v, %v", result, err)
+	}
+
+	result, err = rotation.ValidateRotation(newApp.Generate())
+	if err != nil || result.Match != basicOTP.MatchNew {
+		t.Fatalf("Expected new code to be accepted, got %+v, %v", result, err)
+	}
+	if !rotation.Retired() {
+		t.Error("Expected old generator to be retired after the new one was used")
+	}
+
+	// A fresh old code is no longer accepted, reported against the new generator.
+	result, err = rotation.ValidateRotation(oldApp.GenerateAt(time.Now().Unix() + 30))
+	if !errors.Is(err, basicOTP.ErrMalformedCode) || result.Match != basicOTP.MatchNone {
+		t.Errorf("Expected retired old code to be rejected, got %+v, %v", result, err)
+	}
+}
+
+func TestRotationCutoff(t *testing.T) {
+	now := time.Now()
+	rotation, oldApp, _ := newTestRotation(&now, now.Add(time.Hour))
+
+	now = now.Add(time.Hour)
+	if rotation.Validate(oldApp.Generate()) {
+		t.Error("Expected old code to be rejected after the cutoff")
+	}
+	if !rotation.Retired() {
+		t.Error("Expected old generator to be retired at the cutoff")
+	}
+}
+
+func TestRotationRejections(t *testing.T) {
+	now := time.Now()
+	rotation, oldApp, _ := newTestRotation(&now, now.Add(time.Hour))
+
+	code := oldApp.Generate()
+	if !rotation.Validate(code) {
+		t.Fatal("Expected old code to be accepted")
+	}
+	if _, err := rotation.ValidateDetailed(code); !errors.Is(err, basicOTP.ErrReplayedCode) {
+		t.Errorf("Expected replayed old code, got %v", err)
+	}
+	if _, err := rotation.ValidateDetailed("1234"); !errors.Is(err, basicOTP.ErrMalformedCode) {
+		t.Errorf("Expected malformed code, got %v", err)
+	}
+	if _, err := rotation.ValidateDetailed("12345678"); !errors.Is(err, basicOTP.ErrInvalidCode) {
+		t.Errorf("Expected invalid code, got %v", err)
+	}
+	if rotation.CodeLength() != 8 {
+		t.Errorf("Expected code length of the new generator, got %d", rotation.CodeLength())
+	}
+}
+
+func TestRotationConcurrentValidation(t *testing.T) {
+	now := time.Now()
+	rotation, oldApp, newApp := newTestRotation(&now, now.Add(time.Hour))
+	codes := []string{oldApp.Generate(), newApp.Generate()}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	accepted := map[string]int{}
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func(code string) {
+			defer wg.Done()
+			if rotation.Validate(code) {
+				mu.Lock()
+				accepted[code]++
+				mu.Unlock()
+			}
+			rotation.Retired()
+		}(codes[i%len(codes)])
+	}
+	wg.Wait()
+
+	if accepted[codes[1]] != 1 || accepted[codes[0]] > 1 {
+		t.Errorf("Expected each code to be accepted at most once, got %v", accepted)
+	}
+}
@@ -0,0 +1,328 @@
+package basicOTP
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sebastian-mora/basicOTP/internal/md4"
+)
+
+// SKeyAlgorithm is the hash function of an S/KEY hash chain.
+type SKeyAlgorithm string
+
+const (
+	SKeyMD4  SKeyAlgorithm = "md4"
+	SKeyMD5  SKeyAlgorithm = "md5"
+	SKeySHA1 SKeyAlgorithm = "sha1"
+)
+
+// SKeyChallenge is an RFC 2289 challenge such as "otp-md5 99 seed",
+// asking for the one-time password at Sequence.
+type SKeyChallenge struct {
+	Algorithm SKeyAlgorithm // Algorithm is the hash function of the chain.
+	Sequence  int           // Sequence is the number of times the hash function is applied.
+	Seed      string        // Seed is the lower case seed mixed into the pass phrase.
+}
+
+// ParseSKeyChallenge parses a challenge of the form "otp-<algorithm> <sequence> <seed>".
+// Text before the challenge and extensions after it, such as "ext", are ignored.
+func ParseSKeyChallenge(challenge string) (SKeyChallenge, error) {
+	fields := strings.Fields(challenge)
+	for i, field := range fields {
+		if !strings.HasPrefix(strings.ToLower(field), "otp-") {
+			continue
+		}
+		if len(fields) < i+3 {
+			break
+		}
+
+		algorithm := SKeyAlgorithm(strings.ToLower(field[len("otp-"):]))
+		if !algorithm.valid() {
+			return SKeyChallenge{}, fmt.Errorf("basicOTP: unsupported S/KEY algorithm %q", algorithm)
+		}
+
+		sequence, err := strconv.Atoi(fields[i+1])
+		if err != nil || sequence < 0 {
+			return SKeyChallenge{}, fmt.Errorf("basicOTP: invalid S/KEY sequence %q", fields[i+1])
+		}
+
+		seed := strings.ToLower(fields[i+2])
+		if !validSeed(seed) {
+			return SKeyChallenge{}, fmt.Errorf("basicOTP: invalid S/KEY seed %q", fields[i+2])
+		}
+
+		return SKeyChallenge{Algorithm: algorithm, Sequence: sequence, Seed: seed}, nil
+	}
+	return SKeyChallenge{}, errors.New("basicOTP: no S/KEY challenge found")
+}
+
+// String formats the challenge as "otp-<algorithm> <sequence> <seed>".
+func (c SKeyChallenge) String() string {
+	return fmt.Sprintf("otp-%s %d %s", c.Algorithm, c.Sequence, c.Seed)
+}
+
+// SKey represents an RFC 2289 One-Time Password generator, the client side of
+// an S/KEY hash chain.
+type SKey struct {
+	algorithm  SKeyAlgorithm
+	seed       string
+	passphrase string
+	Sequence   int // Sequence is the sequence number of the next password.
+}
+
+// SKeyConfig holds configuration parameters for S/KEY generation.
+type SKeyConfig struct {
+	Algorithm  SKeyAlgorithm // Algorithm is the hash function of the chain, defaults to SKeyMD5.
+	Seed       string        // Seed is 1 to 16 alphanumeric characters, compared case-insensitively.
+	Passphrase string        // Passphrase is the secret pass phrase, RFC 2289 requires at least 10 characters.
+	Sequence   int           // Sequence is the sequence number of the next password.
+}
+
+// NewSKey creates a new instance of SKey based on the provided configuration.
+func NewSKey(config SKeyConfig) *SKey {
+	if config.Algorithm == "" {
+		config.Algorithm = SKeyMD5
+	}
+	if !config.Algorithm.valid() {
+		panic("unsupported S/KEY algorithm " + string(config.Algorithm))
+	}
+
+	return &SKey{
+		algorithm:  config.Algorithm,
+		seed:       strings.ToLower(config.Seed),
+		passphrase: config.Passphrase,
+		Sequence:   config.Sequence,
+	}
+}
+
+// Challenge returns the challenge answered by the next password.
+func (s *SKey) Challenge() SKeyChallenge {
+	return SKeyChallenge{Algorithm: s.algorithm, Sequence: s.Sequence, Seed: s.seed}
+}
+
+// Generate returns the next password as six words and decrements the sequence number.
+func (s *SKey) Generate() string {
+	words := SKeyWords(s.OTP(s.Sequence))
+	s.Sequence--
+	return words
+}
+
+// OTP computes the 64 bit password at sequence, the seed and pass phrase
+// hashed and then rehashed sequence times.
+func (s *SKey) OTP(sequence int) [8]byte {
+	otp := s.algorithm.sum([]byte(s.seed + s.passphrase))
+	for i := 0; i < sequence; i++ {
+		otp = s.algorithm.sum(otp[:])
+	}
+	return otp
+}
+
+// SKeyState holds the verifier state of an S/KEY hash chain.
+// Only the last accepted password is kept, it can not be used to compute the next one.
+type SKeyState struct {
+	Algorithm SKeyAlgorithm `json:"algorithm"` // Algorithm is the hash function of the chain.
+	Seed      string        `json:"seed"`      // Seed is the lower case seed.
+	Sequence  int           `json:"sequence"`  // Sequence is the sequence number of the last accepted password.
+	Last      []byte        `json:"last"`      // Last is the 8 byte last accepted password.
+}
+
+// SKeyVerifier validates the passwords of an S/KEY hash chain.
+type SKeyVerifier struct {
+	state SKeyState
+}
+
+// NewSKeyVerifier creates a verifier from its state. A new chain starts with
+// the password at a high sequence number, obtained from the user at enrollment.
+func NewSKeyVerifier(state SKeyState) (*SKeyVerifier, error) {
+	if !state.Algorithm.valid() {
+		return nil, fmt.Errorf("basicOTP: unsupported S/KEY algorithm %q", state.Algorithm)
+	}
+	if !validSeed(state.Seed) || len(state.Last) != 8 {
+		return nil, errors.New("basicOTP: invalid S/KEY state")
+	}
+
+	state.Seed = strings.ToLower(state.Seed)
+	return &SKeyVerifier{state: state}, nil
+}
+
+// Challenge returns the challenge to present to the user.
+func (v *SKeyVerifier) Challenge() SKeyChallenge {
+	return SKeyChallenge{Algorithm: v.state.Algorithm, Sequence: v.state.Sequence - 1, Seed: v.state.Seed}
+}
+
+// Validate validates a six word or hexadecimal response to the current challenge.
+func (v *SKeyVerifier) Validate(response string) bool {
+	result, _ := v.ValidateDetailed(response)
+	return result.Valid
+}
+
+// ValidateDetailed validates a response to the current challenge. An accepted
+// response replaces the stored password and the sequence number is decremented,
+// the reported Counter is the sequence number of the response.
+// Once the sequence number reaches zero the chain is exhausted and must be reinitialized.
+func (v *SKeyVerifier) ValidateDetailed(response string) (ValidationResult, error) {
+	otp, err := ParseSKeyResponse(response)
+	if err != nil {
+		return reject(v.state.Sequence-1, ReasonMalformedCode)
+	}
+
+	if subtle.ConstantTimeCompare(otp[:], v.state.Last) == 1 {
+		return reject(v.state.Sequence, ReasonReplayedCode)
+	}
+
+	next := v.state.Algorithm.sum(otp[:])
+	if v.state.Sequence <= 0 || subtle.ConstantTimeCompare(next[:], v.state.Last) != 1 {
+		return reject(v.state.Sequence-1, ReasonInvalidCode)
+	}
+
+	v.state.Sequence--
+	v.state.Last = otp[:]
+	return ValidationResult{Valid: true, Counter: v.state.Sequence, Advanced: true}, nil
+}
+
+// State returns the current verifier state.
+func (v *SKeyVerifier) State() SKeyState {
+	state := v.state
+	state.Last = append([]byte(nil), v.state.Last...)
+	return state
+}
+
+// SKeyWords encodes a password as six words from the RFC 2289 dictionary.
+// The 64 bits are followed by a 2 bit checksum and split into 11 bit word indexes.
+func SKeyWords(otp [8]byte) string {
+	value := binary.BigEndian.Uint64(otp[:])
+	checksum := skeyChecksum(value)
+
+	words := make([]string, 6)
+	for i := range words[:5] {
+		words[i] = skeyWords[value>>(53-11*i)&0x7ff]
+	}
+	words[5] = skeyWords[(value<<2|checksum)&0x7ff]
+	return strings.Join(words, " ")
+}
+
+// SKeyHex encodes a password as 16 upper case hexadecimal digits.
+func SKeyHex(otp [8]byte) string {
+	return strings.ToUpper(hex.EncodeToString(otp[:]))
+}
+
+// ParseSKeyResponse decodes a password given as six words, ignoring case,
+// or as 16 hexadecimal digits, ignoring white space.
+func ParseSKeyResponse(response string) ([8]byte, error) {
+	var otp [8]byte
+
+	fields := strings.Fields(strings.ToUpper(response))
+	if len(fields) == 6 {
+		if value, ok := skeyDecodeWords(fields); ok {
+			binary.BigEndian.PutUint64(otp[:], value)
+			return otp, nil
+		}
+	}
+
+	decoded, err := hex.DecodeString(strings.Join(fields, ""))
+	if err != nil || len(decoded) != len(otp) {
+		return otp, errors.New("basicOTP: invalid S/KEY response")
+	}
+	copy(otp[:], decoded)
+	return otp, nil
+}
+
+// skeyDecodeWords decodes six dictionary words, verifying the checksum.
+func skeyDecodeWords(words []string) (uint64, bool) {
+	var value uint64
+	var last int
+	for i, word := range words {
+		index, ok := skeyWordIndex(word)
+		if !ok {
+			return 0, false
+		}
+		if i < 5 {
+			value |= uint64(index) << (53 - 11*i)
+		}
+		last = index
+	}
+
+	// The last word holds the low 9 bits followed by the checksum.
+	value |= uint64(last) >> 2
+	return value, skeyChecksum(value) == uint64(last)&3
+}
+
+// skeyWordIndex returns the index of word in the dictionary. Each half of the
+// dictionary is sorted, short words come first.
+func skeyWordIndex(word string) (int, bool) {
+	if len(word) == 0 || len(word) > 4 {
+		return 0, false
+	}
+
+	low, high := 0, 571
+	if len(word) == 4 {
+		low, high = 571, len(skeyWords)
+	}
+	for low < high {
+		mid := (low + high) / 2
+		switch {
+		case skeyWords[mid] == word:
+			return mid, true
+		case skeyWords[mid] < word:
+			low = mid + 1
+		default:
+			high = mid
+		}
+	}
+	return 0, false
+}
+
+// skeyChecksum sums the 2 bit pairs of value modulo 4.
+func skeyChecksum(value uint64) uint64 {
+	var sum uint64
+	for i := 0; i < 64; i += 2 {
+		sum += value >> i & 3
+	}
+	return sum & 3
+}
+
+// sum hashes data and folds the result to 64 bits as described in RFC 2289 appendix A.
+func (a SKeyAlgorithm) sum(data []byte) [8]byte {
+	var folded [8]byte
+	switch a {
+	case SKeyMD4:
+		digest := md4.Sum(data)
+		subtle.XORBytes(folded[:], digest[:8], digest[8:])
+	case SKeyMD5:
+		digest := md5.Sum(data)
+		subtle.XORBytes(folded[:], digest[:8], digest[8:])
+	case SKeySHA1:
+		digest := sha1.Sum(data)
+		subtle.XORBytes(folded[:], digest[:8], digest[8:16])
+		subtle.XORBytes(folded[:4], folded[:4], digest[16:])
+		// SHA1 words are stored little-endian.
+		binary.LittleEndian.PutUint32(folded[:4], binary.BigEndian.Uint32(folded[:4]))
+		binary.LittleEndian.PutUint32(folded[4:], binary.BigEndian.Uint32(folded[4:]))
+	}
+	return folded
+}
+
+// valid reports whether a is a supported algorithm.
+func (a SKeyAlgorithm) valid() bool {
+	return a == SKeyMD4 || a == SKeyMD5 || a == SKeySHA1
+}
+
+// validSeed reports whether seed has 1 to 16 alphanumeric characters.
+func validSeed(seed string) bool {
+	if len(seed) == 0 || len(seed) > 16 {
+		return false
+	}
+	for _, c := range seed {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,149 @@
+package basicOTP_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sebastian-mora/basicOTP"
+)
+
+/*
+Test data was taken from https://datatracker.ietf.org/doc/html/rfc2289
+Appendix C
+*/
+var skeyTestCases = []struct {
+	Algorithm  basicOTP.SKeyAlgorithm
+	Passphrase string
+	Seed       string
+	Sequence   int
+	Hex        string
+	Words      string
+}{
+	{basicOTP.SKeyMD4, "This is a test.", "TeSt", 0, "D1854218EBBB0B51", "ROME MUG FRED SCAN LIVE LACE"},
+	{basicOTP.SKeyMD4, "This is a test.", "TeSt", 1, "63473EF01CD0B444", "CARD SAD MINI RYE COL KIN"},
+	{basicOTP.SKeyMD4, "This is a test.", "TeSt", 99, "C5E612776E6C237A", "NOTE OUT IBIS SINK NAVE MODE"},
+	{basicOTP.SKeyMD4, "AbCdEfGhIjK", "alpha1", 0, "50076F47EB1ADE4E", "AWAY SEN ROOK SALT LICE MAP"},
+	{basicOTP.SKeyMD4, "AbCdEfGhIjK", "alpha1", 1, "65D20D1949B5F7AB", "CHEW GRIM WU HANG BUCK SAID"},
+	{basicOTP.SKeyMD4, "AbCdEfGhIjK", "alpha1", 99, "D150C82CCE6F62D1", "ROIL FREE COG HUNK WAIT COCA"},
+	{basicOTP.SKeyMD4, "OTP's are good", "correct", 0, "849C79D4F6F55388", "FOOL STEM DONE TOOL BECK NILE"},
+	{basicOTP.SKeyMD4, "OTP's are good", "correct", 1, "8C0992FB250847B1", "GIST AMOS MOOT AIDS FOOD SEEM"},
+	{basicOTP.SKeyMD4, "OTP's are good", "correct", 99, "3F3BF4B4145FD74B", "TAG SLOW NOV MIN WOOL KENO"},
+	{basicOTP.SKeyMD5, "This is a test.", "TeSt", 0, "9E876134D90499DD", "INCH SEA ANNE LONG AHEM TOUR"},
+	{basicOTP.SKeyMD5, "This is a test.", "TeSt", 1, "7965E05436F5029F", "EASE OIL FUM CURE AWRY AVIS"},
+	{basicOTP.SKeyMD5, "This is a test.", "TeSt", 99, "50FE1962C4965880", "BAIL TUFT BITS GANG CHEF THY"},
+	{basicOTP.SKeyMD5, "AbCdEfGhIjK", "alpha1", 0, "87066DD9644BF206", "FULL PEW DOWN ONCE MORT ARC"},
+	{basicOTP.SKeyMD5, "AbCdEfGhIjK", "alpha1", 1, "7CD34C1040ADD14B", "FACT HOOF AT FIST SITE KENT"},
+	{basicOTP.SKeyMD5, "AbCdEfGhIjK", "alpha1", 99, "5AA37A81F212146C", "BODE HOP JAKE STOW JUT RAP"},
+	{basicOTP.SKeyMD5, "OTP's are good", "correct", 0, "F205753943DE4CF9", "ULAN NEW ARMY FUSE SUIT EYED"},
+	{basicOTP.SKeyMD5, "OTP's are good", "correct", 1, "DDCDAC956F234937", "SKIM CULT LOB SLAM POE HOWL"},
+	{basicOTP.SKeyMD5, "OTP's are good", "correct", 99, "B203E28FA525BE47", "LONG IVY JULY AJAR BOND LEE"},
+	{basicOTP.SKeySHA1, "This is a test.", "TeSt", 0, "BB9E6AE1979D8FF4", "MILT VARY MAST OK SEES WENT"},
+	{basicOTP.SKeySHA1, "This is a test.", "TeSt", 1, "63D936639734385B", "CART OTTO HIVE ODE VAT NUT"},
+	{basicOTP.SKeySHA1, "This is a test.", "TeSt", 99, "87FEC7768B73CCF9", "GAFF WAIT SKID GIG SKY EYED"},
+	{basicOTP.SKeySHA1, "AbCdEfGhIjK", "alpha1", 0, "AD85F658EBE383C9", "LEST OR HEEL SCOT ROB SUIT"},
+	{basicOTP.SKeySHA1, "AbCdEfGhIjK", "alpha1", 1, "D07CE229B5CF119B", "RITE TAKE GELD COST TUNE RECK"},
+	{basicOTP.SKeySHA1, "AbCdEfGhIjK", "alpha1", 99, "27BC71035AAF3DC6", "MAY STAR TIN LYON VEDA STAN"},
+	{basicOTP.SKeySHA1, "OTP's are good", "correct", 0, "D51F3E99BF8E6F0B", "RUST WELT KICK FELL TAIL FRAU"},
+	{basicOTP.SKeySHA1, "OTP's are good", "correct", 1, "82AEB52D943774E4", "FLIT DOSE ALSO MEW DRUM DEFY"},
+	{basicOTP.SKeySHA1, "OTP's are good", "correct", 99, "4F296A74FE1567EC", "AURA ALOE HURL WING BERG WAIT"},
+}
+
+func TestSKeyVectors(t *testing.T) {
+	for _, tc := range skeyTestCases {
+		skey := basicOTP.NewSKey(basicOTP.SKeyConfig{Algorithm: tc.Algorithm, Seed: tc.Seed, Passphrase: tc.Passphrase})
+		otp := skey.OTP(tc.Sequence)
+
+		if hex := basicOTP.SKeyHex(otp); hex != tc.Hex {
+			t.Errorf("%s %q %d: expected hex %s, got %s", tc.Algorithm, tc.Passphrase, tc.Sequence, tc.Hex, hex)
+		}
+		if words := basicOTP.SKeyWords(otp); words != tc.Words {
+			t.Errorf("%s %q %d: expected words %s, got %s", tc.Algorithm, tc.Passphrase, tc.Sequence, tc.Words, words)
+		}
+
+		for _, response := range []string{tc.Words, tc.Hex} {
+			parsed, err := basicOTP.ParseSKeyResponse(response)
+			if err != nil || parsed != otp {
+				t.Errorf("ParseSKeyResponse(%q): expected %s, got %X, %v", response, tc.Hex, parsed, err)
+			}
+		}
+	}
+}
+
+func TestParseSKeyResponse(t *testing.T) {
+	for _, response := range []string{"rome mug fred scan live lace", "d185 4218 ebbb 0b51", "  D1854218EBBB0B51\n"} {
+		otp, err := basicOTP.ParseSKeyResponse(response)
+		if err != nil || basicOTP.SKeyHex(otp) != "D1854218EBBB0B51" {
+			t.Errorf("ParseSKeyResponse(%q): got %X, %v", response, otp, err)
+		}
+	}
+
+	// LACY only differs from the valid LACK in the checksum bits.
+	for _, response := range []string{"ROME MUG FRED SCAN LIVE LACY", "ROME MUG FRED SCAN LIVE", "ROME MUG FRED SCAN LIVE ZZZZ", "D1854218EBBB0B", ""} {
+		if _, err := basicOTP.ParseSKeyResponse(response); err == nil {
+			t.Errorf("ParseSKeyResponse(%q): expected error", response)
+		}
+	}
+}
+
+func TestParseSKeyChallenge(t *testing.T) {
+	challenge, err := basicOTP.ParseSKeyChallenge("Challenge? otp-MD5 99 TeSt ext")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := basicOTP.SKeyChallenge{Algorithm: basicOTP.SKeyMD5, Sequence: 99, Seed: "test"}
+	if challenge != expected {
+		t.Errorf("Expected %+v, got %+v", expected, challenge)
+	}
+	if challenge.String() != "otp-md5 99 test" {
+		t.Errorf("Unexpected challenge string %q", challenge.String())
+	}
+
+	for _, invalid := range []string{"otp-sha256 99 seed", "otp-md5 -1 seed", "otp-md5 99 se-ed", "otp-md5 99", "s/key 99 seed"} {
+		if _, err := basicOTP.ParseSKeyChallenge(invalid); err == nil {
+			t.Errorf("ParseSKeyChallenge(%q): expected error", invalid)
+		}
+	}
+}
+
+func TestSKeyVerifier(t *testing.T) {
+	client := basicOTP.NewSKey(basicOTP.SKeyConfig{Algorithm: basicOTP.SKeySHA1, Seed: "alpha1", Passphrase: "AbCdEfGhIjK", Sequence: 100})
+	initial := client.OTP(100)
+	client.Sequence = 99
+
+	verifier, err := basicOTP.NewSKeyVerifier(basicOTP.SKeyState{Algorithm: basicOTP.SKeySHA1, Seed: "alpha1", Sequence: 100, Last: initial[:]})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if verifier.Challenge() != client.Challenge() {
+		t.Fatalf("Expected challenge %v, got %v", client.Challenge(), verifier.Challenge())
+	}
+
+	response := client.Generate()
+	if response != "MAY STAR TIN LYON VEDA STAN" {
+		t.Errorf("Unexpected response %s", response)
+	}
+	result, err := verifier.ValidateDetailed(response)
+	if err != nil || result.Counter != 99 {
+		t.Fatalf("Expected response to be accepted at 99, got %+v, %v", result, err)
+	}
+
+	if _, err := verifier.ValidateDetailed(response); !errors.Is(err, basicOTP.ErrReplayedCode) {
+		t.Errorf("Expected replayed error, got %v", err)
+	}
+	if _, err := verifier.ValidateDetailed("not a response"); !errors.Is(err, basicOTP.ErrMalformedCode) {
+		t.Errorf("Expected malformed error, got %v", err)
+	}
+	client.Generate() // skip a password
+	if _, err := verifier.ValidateDetailed(client.Generate()); !errors.Is(err, basicOTP.ErrInvalidCode) {
+		t.Errorf("Expected invalid error for a skipped password, got %v", err)
+	}
+
+	state := verifier.State()
+	if state.Sequence != 99 || basicOTP.SKeyHex([8]byte(state.Last)) != "27BC71035AAF3DC6" {
+		t.Errorf("Unexpected state %+v", state)
+	}
+
+	if !verifier.Validate(basicOTP.SKeyHex(client.OTP(98))) {
+		t.Error("Expected hexadecimal response to be accepted")
+	}
+}
@@ -0,0 +1,331 @@
+package sqlstore_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// fakeDriver is an in-memory database/sql driver understanding just enough
+// SQL for the queries of the store. Each DSN is a separate database.
+// Transactions are accepted but not isolated or rolled back.
+type fakeDriver struct {
+	mu  sync.Mutex
+	dbs map[string]*fakeDB
+}
+
+func init() {
+	sql.Register("sqlstorefake", &fakeDriver{dbs: map[string]*fakeDB{}})
+}
+
+// fakeDB holds the tables of a database and every statement executed.
+type fakeDB struct {
+	dsn        string
+	mu         sync.Mutex
+	tables     map[string]*fakeTable
+	statements []string
+	beforeExec func(query string) // beforeExec is called without holding mu before each statement.
+}
+
+type fakeTable struct {
+	columns []string
+	primary int // primary is the index of the primary key column.
+	rows    [][]driver.Value
+}
+
+func (d *fakeDriver) Open(dsn string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	db, ok := d.dbs[dsn]
+	if !ok {
+		db = &fakeDB{dsn: dsn, tables: map[string]*fakeTable{}}
+		d.dbs[dsn] = db
+	}
+	return &fakeConn{db: db}, nil
+}
+
+// openFake opens the fake database named dsn, creating it if needed, and
+// returns it with its internals.
+func openFake(dsn string) (*sql.DB, *fakeDB) {
+	db, err := sql.Open("sqlstorefake", dsn)
+	if err != nil {
+		panic(err)
+	}
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		panic(err)
+	}
+	defer conn.Close()
+
+	var fake *fakeDB
+	conn.Raw(func(c any) error {
+		fake = c.(*fakeConn).db
+		return nil
+	})
+	return db, fake
+}
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{db: c.db, query: query}, nil
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeStmt struct {
+	db    *fakeDB
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	_, n, err := s.db.run(s.query, args)
+	return driver.RowsAffected(n), err
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	rows, _, err := s.db.run(s.query, args)
+	if err == nil && rows == nil {
+		return nil, fmt.Errorf("fake: %q returns no rows", s.query)
+	}
+	return rows, err
+}
+
+var (
+	createPattern = regexp.MustCompile(`(?s)^CREATE TABLE (IF NOT EXISTS )?(\w+) \((.*)\)$`)
+	maxPattern    = regexp.MustCompile(`^SELECT MAX\((\w+)\) FROM (\w+)$`)
+	insertPattern = regexp.MustCompile(`^INSERT INTO (\w+) \(([^)]*)\) VALUES \(([^)]*)\)$`)
+	selectPattern = regexp.MustCompile(`^SELECT (.*) FROM (\w+) WHERE (.*)$`)
+	updatePattern = regexp.MustCompile(`^UPDATE (\w+) SET (.*) WHERE (.*)$`)
+	deletePattern = regexp.MustCompile(`^DELETE FROM (\w+) WHERE (.*)$`)
+	paramPattern  = regexp.MustCompile(`^(\?|\$(\d+))$`)
+)
+
+// run executes query, returning the selected rows or the number of affected rows.
+func (db *fakeDB) run(query string, args []driver.Value) (*fakeRows, int64, error) {
+	if db.beforeExec != nil {
+		db.beforeExec(query)
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.statements = append(db.statements, query)
+	next := 0
+	param := func(p string) (driver.Value, error) {
+		m := paramPattern.FindStringSubmatch(strings.TrimSpace(p))
+		if m == nil {
+			return nil, fmt.Errorf("fake: expected parameter, got %q", p)
+		}
+		i := next
+		next++
+		if m[2] != "" {
+			i, _ = strconv.Atoi(m[2])
+			i--
+		}
+		if i >= len(args) {
+			return nil, fmt.Errorf("fake: missing argument %d", i+1)
+		}
+		return args[i], nil
+	}
+
+	if m := createPattern.FindStringSubmatch(query); m != nil {
+		if _, ok := db.tables[m[2]]; ok {
+			if m[1] != "" {
+				return nil, 0, nil
+			}
+			return nil, 0, fmt.Errorf("fake: table %s exists", m[2])
+		}
+		table := &fakeTable{}
+		for i, def := range strings.Split(m[3], ",") {
+			table.columns = append(table.columns, strings.Fields(def)[0])
+			if strings.Contains(def, "PRIMARY KEY") {
+				table.primary = i
+			}
+		}
+		db.tables[m[2]] = table
+		return nil, 0, nil
+	}
+
+	if m := maxPattern.FindStringSubmatch(query); m != nil {
+		table, err := db.table(m[2])
+		if err != nil {
+			return nil, 0, err
+		}
+		var max driver.Value
+		column := table.column(m[1])
+		for _, row := range table.rows {
+			if max == nil || row[column].(int64) > max.(int64) {
+				max = row[column]
+			}
+		}
+		return &fakeRows{columns: []string{"max"}, rows: [][]driver.Value{{max}}}, 0, nil
+	}
+
+	if m := insertPattern.FindStringSubmatch(query); m != nil {
+		table, err := db.table(m[1])
+		if err != nil {
+			return nil, 0, err
+		}
+		row := make([]driver.Value, len(table.columns))
+		values := strings.Split(m[3], ",")
+		for i, name := range strings.Split(m[2], ",") {
+			if row[table.column(strings.TrimSpace(name))], err = param(values[i]); err != nil {
+				return nil, 0, err
+			}
+		}
+		for _, existing := range table.rows {
+			if existing[table.primary] == row[table.primary] {
+				return nil, 0, fmt.Errorf("fake: duplicate key %v", row[table.primary])
+			}
+		}
+		table.rows = append(table.rows, row)
+		return nil, 1, nil
+	}
+
+	if m := selectPattern.FindStringSubmatch(query); m != nil {
+		table, err := db.table(m[2])
+		if err != nil {
+			return nil, 0, err
+		}
+		names := strings.Split(m[1], ",")
+		match, err := table.where(m[3], param)
+		if err != nil {
+			return nil, 0, err
+		}
+		result := &fakeRows{columns: names}
+		for _, row := range table.rows {
+			if match(row) {
+				selected := make([]driver.Value, len(names))
+				for i, name := range names {
+					selected[i] = row[table.column(strings.TrimSpace(name))]
+				}
+				result.rows = append(result.rows, selected)
+			}
+		}
+		return result, 0, nil
+	}
+
+	if m := updatePattern.FindStringSubmatch(query); m != nil {
+		table, err := db.table(m[1])
+		if err != nil {
+			return nil, 0, err
+		}
+		assignments := map[int]driver.Value{}
+		for _, set := range strings.Split(m[2], ",") {
+			name, value, _ := strings.Cut(set, "=")
+			if assignments[table.column(strings.TrimSpace(name))], err = param(value); err != nil {
+				return nil, 0, err
+			}
+		}
+		match, err := table.where(m[3], param)
+		if err != nil {
+			return nil, 0, err
+		}
+		var n int64
+		for _, row := range table.rows {
+			if match(row) {
+				for column, value := range assignments {
+					row[column] = value
+				}
+				n++
+			}
+		}
+		return nil, n, nil
+	}
+
+	if m := deletePattern.FindStringSubmatch(query); m != nil {
+		table, err := db.table(m[1])
+		if err != nil {
+			return nil, 0, err
+		}
+		match, err := table.where(m[2], param)
+		if err != nil {
+			return nil, 0, err
+		}
+		kept := table.rows[:0]
+		for _, row := range table.rows {
+			if !match(row) {
+				kept = append(kept, row)
+			}
+		}
+		n := int64(len(table.rows) - len(kept))
+		table.rows = kept
+		return nil, n, nil
+	}
+
+	return nil, 0, fmt.Errorf("fake: unsupported query %q", query)
+}
+
+func (db *fakeDB) table(name string) (*fakeTable, error) {
+	table, ok := db.tables[name]
+	if !ok {
+		return nil, fmt.Errorf("fake: no table %s", name)
+	}
+	return table, nil
+}
+
+// column returns the index of a column, or -1.
+func (t *fakeTable) column(name string) int {
+	for i, column := range t.columns {
+		if column == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// where parses conditions of the form "a = ? AND b = ?".
+func (t *fakeTable) where(conditions string, param func(string) (driver.Value, error)) (func([]driver.Value) bool, error) {
+	want := map[int]driver.Value{}
+	for _, condition := range strings.Split(conditions, " AND ") {
+		name, value, _ := strings.Cut(condition, "=")
+		v, err := param(value)
+		if err != nil {
+			return nil, err
+		}
+		want[t.column(strings.TrimSpace(name))] = v
+	}
+	return func(row []driver.Value) bool {
+		for column, value := range want {
+			if row[column] != value {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
@@ -0,0 +1,275 @@
+// Package sqlstore keeps TOTP and HOTP credentials in a database/sql database.
+//
+// The queries and migrations only use SQL that PostgreSQL, MySQL and SQLite
+// have in common, the bind parameter syntax is chosen with Config.Placeholder.
+//
+// Counters are updated with optimistic concurrency: an update only applies if
+// the stored counter and last used time step are still the ones that were read,
+// so two nodes validating the same code at the same time can not both accept it.
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sebastian-mora/basicOTP"
+)
+
+var (
+	// ErrNotFound is returned when no credential is stored under an ID.
+	ErrNotFound = errors.New("sqlstore: credential not found")
+	// ErrConflict is returned by Advance when the credential was updated since it was read.
+	ErrConflict = errors.New("sqlstore: credential was updated concurrently")
+)
+
+// Placeholder returns the bind parameter of the nth argument of a query, starting at 1.
+type Placeholder func(n int) string
+
+var (
+	// Question binds parameters as "?", as used by MySQL and SQLite.
+	Question Placeholder = func(int) string { return "?" }
+	// Dollar binds parameters as "$1", "$2", ..., as used by PostgreSQL.
+	Dollar Placeholder = func(n int) string { return "$" + strconv.Itoa(n) }
+)
+
+// migrations are the schema changes of the credential table in order, %[1]s is the table name.
+// Applied migrations are recorded in the %[1]s_migrations table.
+var migrations = []string{
+	`CREATE TABLE %[1]s (
+		id VARCHAR(255) NOT NULL PRIMARY KEY,
+		otp_key TEXT NOT NULL,
+		counter BIGINT NOT NULL,
+		last_step BIGINT NOT NULL,
+		drift INTEGER NOT NULL
+	)`,
+}
+
+// Credential is a stored generator together with its validation state.
+type Credential struct {
+	ID    string             // ID identifies the credential, such as a user ID.
+	Key   basicOTP.Key       // Key holds the parameters of the generator, Key.Counter is the HOTP counter.
+	State basicOTP.TOTPState // State is the TOTP validation state, unused for HOTP.
+}
+
+// Config holds configuration parameters for a Store.
+type Config struct {
+	Table                string      // Table is the name of the credential table, defaults to "otp_credentials". It is not escaped.
+	Placeholder          Placeholder // Placeholder formats bind parameters, defaults to Question.
+	Window               int         // Window is the TOTP validation window used by Validate.
+	SynchronizationLimit int         // SynchronizationLimit is the HOTP look-ahead used by Validate.
+}
+
+// Store keeps credentials in a database table.
+type Store struct {
+	db                   *sql.DB
+	table                string
+	placeholder          Placeholder
+	window               int
+	synchronizationLimit int
+}
+
+// New creates a Store using db based on the provided configuration.
+func New(db *sql.DB, config Config) *Store {
+	if config.Table == "" {
+		config.Table = "otp_credentials"
+	}
+	if config.Placeholder == nil {
+		config.Placeholder = Question
+	}
+
+	return &Store{
+		db:                   db,
+		table:                config.Table,
+		placeholder:          config.Placeholder,
+		window:               config.Window,
+		synchronizationLimit: config.SynchronizationLimit,
+	}
+}
+
+// Migrate creates or upgrades the credential table. Each migration is applied
+// once in a transaction, run Migrate from a single node, for example on deploy.
+func (s *Store) Migrate(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s_migrations (version INTEGER NOT NULL PRIMARY KEY)", s.table)); err != nil {
+		return err
+	}
+
+	var applied sql.NullInt64
+	if err := s.db.QueryRowContext(ctx, fmt.Sprintf("SELECT MAX(version) FROM %s_migrations", s.table)).Scan(&applied); err != nil {
+		return err
+	}
+
+	for version := int(applied.Int64) + 1; version <= len(migrations); version++ {
+		if err := s.migrate(ctx, version); err != nil {
+			return fmt.Errorf("sqlstore: migration %d: %w", version, err)
+		}
+	}
+	return nil
+}
+
+// migrate applies a single migration and records it.
+func (s *Store) migrate(ctx context.Context, version int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(migrations[version-1], s.table)); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, s.query("INSERT INTO %s_migrations (version) VALUES (?)"), version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Create stores a new credential. Storing an ID twice fails with the
+// constraint error of the database.
+func (s *Store) Create(ctx context.Context, c Credential) error {
+	key, err := encodeKey(c.Key)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx,
+		s.query("INSERT INTO %s (id, otp_key, counter, last_step, drift) VALUES (?, ?, ?, ?, ?)"),
+		c.ID, key, c.Key.Counter, c.State.LastStep, c.State.Drift)
+	return err
+}
+
+// Get returns the credential stored under id.
+func (s *Store) Get(ctx context.Context, id string) (Credential, error) {
+	var key string
+	var counter, lastStep int64
+	var drift int
+	err := s.db.QueryRowContext(ctx,
+		s.query("SELECT otp_key, counter, last_step, drift FROM %s WHERE id = ?"), id).
+		Scan(&key, &counter, &lastStep, &drift)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Credential{}, ErrNotFound
+	}
+	if err != nil {
+		return Credential{}, err
+	}
+
+	c := Credential{ID: id, State: basicOTP.TOTPState{Drift: drift, LastStep: int(lastStep)}}
+	if err := json.Unmarshal([]byte(key), &c.Key); err != nil {
+		return Credential{}, err
+	}
+	c.Key.Counter = int(counter)
+	return c, nil
+}
+
+// Delete removes the credential stored under id.
+func (s *Store) Delete(ctx context.Context, id string) error {
+	result, err := s.db.ExecContext(ctx, s.query("DELETE FROM %s WHERE id = ?"), id)
+	if err != nil {
+		return err
+	}
+	return expectRow(result, ErrNotFound)
+}
+
+// Advance stores the counter and state of next if the stored ones are still
+// those of current, as read by Get. Otherwise another node has updated the
+// credential in the meantime and ErrConflict is returned. The key itself is
+// never changed, to replace it delete and create the credential.
+func (s *Store) Advance(ctx context.Context, current Credential, next Credential) error {
+	result, err := s.db.ExecContext(ctx,
+		s.query("UPDATE %s SET counter = ?, last_step = ?, drift = ? WHERE id = ? AND counter = ? AND last_step = ?"),
+		next.Key.Counter, next.State.LastStep, next.State.Drift,
+		current.ID, current.Key.Counter, current.State.LastStep)
+	if err != nil {
+		return err
+	}
+	return expectRow(result, ErrConflict)
+}
+
+// Validate validates code against the credential stored under id and saves
+// the advanced counter or time step with Advance. If another node accepted a
+// code of the same credential concurrently, code is rejected as replayed.
+func (s *Store) Validate(ctx context.Context, id string, code string) (basicOTP.ValidationResult, error) {
+	current, err := s.Get(ctx, id)
+	if err != nil {
+		return basicOTP.ValidationResult{}, err
+	}
+
+	next := current
+	var result basicOTP.ValidationResult
+	switch current.Key.Type {
+	case "totp":
+		totp := basicOTP.NewTOTP(basicOTP.TOTPConfig{
+			TimeInterval: current.Key.Period,
+			CodeLength:   current.Key.CodeLength,
+			HashType:     current.Key.HashType,
+			Secret:       current.Key.Secret,
+			Window:       s.window,
+		})
+		totp.SetState(current.State)
+		result, err = totp.ValidateContext(ctx, code)
+		next.State = totp.State()
+	case "hotp":
+		hotp := basicOTP.NewHTOP(basicOTP.HOTPConfig{
+			CodeLength:           current.Key.CodeLength,
+			HashType:             current.Key.HashType,
+			Secret:               current.Key.Secret,
+			Counter:              current.Key.Counter,
+			SynchronizationLimit: s.synchronizationLimit,
+		})
+		result, err = hotp.ValidateContext(ctx, code)
+		next.Key.Counter = hotp.Counter
+	default:
+		return basicOTP.ValidationResult{}, fmt.Errorf("sqlstore: unsupported type %q", current.Key.Type)
+	}
+	if err != nil {
+		return result, err
+	}
+
+	err = s.Advance(ctx, current, next)
+	if errors.Is(err, ErrConflict) {
+		return basicOTP.ValidationResult{Counter: result.Counter, Reason: basicOTP.ReasonReplayedCode}, basicOTP.ErrReplayedCode
+	}
+	if err != nil {
+		return basicOTP.ValidationResult{Counter: result.Counter}, err
+	}
+	return result, nil
+}
+
+// query formats a query for the table, replacing each "?" with a placeholder.
+func (s *Store) query(format string) string {
+	query := fmt.Sprintf(format, s.table)
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r != '?' {
+			b.WriteRune(r)
+			continue
+		}
+		n++
+		b.WriteString(s.placeholder(n))
+	}
+	return b.String()
+}
+
+// encodeKey serializes key without its counter, which is stored in its own column.
+func encodeKey(key basicOTP.Key) (string, error) {
+	key.Counter = 0
+	data, err := json.Marshal(key)
+	return string(data), err
+}
+
+// expectRow returns err if result did not affect any row.
+func expectRow(result sql.Result, err error) error {
+	n, rowsErr := result.RowsAffected()
+	if rowsErr != nil {
+		return rowsErr
+	}
+	if n == 0 {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package sqlstore_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sebastian-mora/basicOTP"
+	"github.com/sebastian-mora/basicOTP/sqlstore"
+)
+
+var (
+	secret    = []byte("12345678901234567890")
+	databases = 0
+)
+
+// newTestStore migrates a store on a new fake database.
+func newTestStore(t *testing.T, config sqlstore.Config) (*sqlstore.Store, *fakeDB) {
+	databases++
+	db, fake := openFake(fmt.Sprintf("%s-%d", t.Name(), databases))
+	store := sqlstore.New(db, config)
+	if err := store.Migrate(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return store, fake
+}
+
+func TestMigrate(t *testing.T) {
+	store, fake := newTestStore(t, sqlstore.Config{})
+	if err := store.Migrate(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	created := 0
+	for _, statement := range fake.statements {
+		if strings.HasPrefix(statement, "CREATE TABLE otp_credentials ") {
+			created++
+		}
+	}
+	if created != 1 {
+		t.Errorf("Expected the credential table to be created once, got %d", created)
+	}
+}
+
+func TestCreateGetDelete(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestStore(t, sqlstore.Config{Table: "mfa"})
+
+	credential := sqlstore.Credential{
+		ID:    "alice",
+		Key:   basicOTP.Key{Type: "hotp", Label: "Example:alice", Secret: secret, HashType: basicOTP.SHA256, CodeLength: 8, Counter: 7},
+		State: basicOTP.TOTPState{},
+	}
+	if err := store.Create(ctx, credential); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := store.Create(ctx, credential); err == nil {
+		t.Error("Expected error for duplicate ID")
+	}
+
+	got, err := store.Get(ctx, "alice")
+	if err != nil || !reflect.DeepEqual(got, credential) {
+		t.Fatalf("Expected %+v, got %+v, %v", credential, got, err)
+	}
+
+	if err := store.Delete(ctx, "alice"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := store.Get(ctx, "alice"); !errors.Is(err, sqlstore.ErrNotFound) {
+		t.Errorf("Expected not found, got %v", err)
+	}
+	if err := store.Delete(ctx, "alice"); !errors.Is(err, sqlstore.ErrNotFound) {
+		t.Errorf("Expected not found, got %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	ctx := context.Background()
+	store, fake := newTestStore(t, sqlstore.Config{Placeholder: sqlstore.Dollar, Window: 1, SynchronizationLimit: 5})
+
+	store.Create(ctx, sqlstore.Credential{ID: "phone", Key: basicOTP.Key{Type: "totp", Secret: secret}})
+	store.Create(ctx, sqlstore.Credential{ID: "token", Key: basicOTP.Key{Type: "hotp", Secret: secret}})
+
+	app := basicOTP.NewTOTP(basicOTP.TOTPConfig{Secret: secret})
+	code := app.Generate()
+	if _, err := store.Validate(ctx, "phone", code); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := store.Validate(ctx, "phone", code); !errors.Is(err, basicOTP.ErrReplayedCode) {
+		t.Errorf("Expected replayed code, got %v", err)
+	}
+
+	token := basicOTP.NewHTOP(basicOTP.HOTPConfig{Secret: secret, Counter: 2})
+	result, err := store.Validate(ctx, "token", token.Generate())
+	if err != nil || result.Counter != 2 {
+		t.Fatalf("Expected counter 2 to be accepted, got %+v, %v", result, err)
+	}
+	if credential, _ := store.Get(ctx, "token"); credential.Key.Counter != 2 {
+		t.Errorf("Expected stored counter to advance, got %d", credential.Key.Counter)
+	}
+
+	if _, err := store.Validate(ctx, "nobody", code); !errors.Is(err, sqlstore.ErrNotFound) {
+		t.Errorf("Expected not found, got %v", err)
+	}
+
+	update := "UPDATE otp_credentials SET counter = $1, last_step = $2, drift = $3 WHERE id = $4 AND counter = $5 AND last_step = $6"
+	found := false
+	for _, statement := range fake.statements {
+		if strings.Contains(statement, "?") {
+			t.Errorf("Expected dollar placeholders, got %q", statement)
+		}
+		found = found || statement == update
+	}
+	if !found {
+		t.Errorf("Expected statement %q", update)
+	}
+}
+
+func TestValidateConcurrentNodes(t *testing.T) {
+	ctx := context.Background()
+	node1, fake := newTestStore(t, sqlstore.Config{SynchronizationLimit: 5})
+	db, _ := openFake(fake.dsn) // the same database, as seen by another node
+	node2 := sqlstore.New(db, sqlstore.Config{SynchronizationLimit: 5})
+	node1.Create(ctx, sqlstore.Credential{ID: "token", Key: basicOTP.Key{Type: "hotp", Secret: secret}})
+
+	code := basicOTP.NewHTOP(basicOTP.HOTPConfig{Secret: secret}).Generate()
+
+	// node2 accepts the code after node1 read the credential but before it saves it.
+	var raced error
+	fake.beforeExec = func(query string) {
+		if strings.HasPrefix(query, "UPDATE") && fake.beforeExec != nil {
+			fake.beforeExec = nil
+			_, raced = node2.Validate(ctx, "token", code)
+		}
+	}
+
+	if _, err := node1.Validate(ctx, "token", code); !errors.Is(err, basicOTP.ErrReplayedCode) {
+		t.Errorf("Expected the slower node to reject the code as replayed, got %v", err)
+	}
+	if raced != nil {
+		t.Errorf("Expected the faster node to accept the code, got %v", raced)
+	}
+	if credential, _ := node1.Get(ctx, "token"); credential.Key.Counter != 1 {
+		t.Errorf("Expected counter to advance once, got %d", credential.Key.Counter)
+	}
+}
+
+func TestAdvanceConflict(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestStore(t, sqlstore.Config{})
+	store.Create(ctx, sqlstore.Credential{ID: "phone", Key: basicOTP.Key{Type: "totp", Secret: secret}})
+
+	current, _ := store.Get(ctx, "phone")
+	next := current
+	next.State = basicOTP.TOTPState{Drift: 1, LastStep: 100}
+	if err := store.Advance(ctx, current, next); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := store.Advance(ctx, current, next); !errors.Is(err, sqlstore.ErrConflict) {
+		t.Errorf("Expected conflict, got %v", err)
+	}
+	if got, _ := store.Get(ctx, "phone"); got.State != next.State {
+		t.Errorf("Expected state %+v, got %+v", next.State, got.State)
+	}
+}
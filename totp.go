@@ -1,24 +1,39 @@
 package basicOTP
 
 import (
+	"context"
 	"encoding/base32"
 	"fmt"
 	"net/url"
+	"sync"
 	"time"
 )
 
 // TOTP represents a Time-based One-Time Password generator.
+// It is safe for concurrent use, validations are serialized so a code is
+// accepted at most once.
 type TOTP struct {
-	otp        OTP // otp is the underlying OTP generator.
-	TimePeriod int // TimePeriod is the time period in seconds used for TOTP generation.
+	mu         sync.Mutex // mu guards lastStep and drift across a validation.
+	otp        OTP        // otp is the underlying OTP generator.
+	TimePeriod int        // TimePeriod is the time period in seconds used for TOTP generation.
+	window     int        // window is the number of time steps accepted on either side of the current one.
+	lastStep   int        // lastStep is the most recent time step accepted, -1 if none.
+	drift      int        // drift is the estimated number of time steps the client clock is ahead of the server.
+	id         string
+	observer   Observer
+	replay     ReplayCache
 }
 
 // TOTPConfig holds configuration parameters for TOTP generation.
 type TOTPConfig struct {
-	TimeInterval int      // TimeInterval is the time interval in seconds for TOTP generation.
-	CodeLength   int      // CodeLength is the length of the generated TOTP code.
-	HashType     HashType // HashType is the hash algorithm used for TOTP generation.
-	Secret       []byte   // Secret is the shared secret key used for TOTP generation.
+	TimeInterval int         // TimeInterval is the time interval in seconds for TOTP generation.
+	CodeLength   int         // CodeLength is the length of the generated TOTP code.
+	HashType     HashType    // HashType is the hash algorithm used for TOTP generation.
+	Secret       []byte      // Secret is the shared secret key used for TOTP generation.
+	Window       int         // Window is the number of time steps before and after the current one accepted during validation.
+	ID           string      // ID identifies the credential in the events reported to Observer and in the ReplayCache.
+	Observer     Observer    // Observer is notified of generate and validate events, if set.
+	ReplayCache  ReplayCache // ReplayCache shares the used time steps with other nodes, if set. It requires an ID.
 }
 
 // NewTOTP creates a new instance of TOTP based on the provided configuration.
@@ -27,34 +42,166 @@ func NewTOTP(config TOTPConfig) *TOTP {
 		// Set the default time interval to 30 seconds, recommended in RFC 6238.
 		config.TimeInterval = 30
 	}
+	if config.ReplayCache != nil && config.ID == "" {
+		panic("replay cache requires a credential ID")
+	}
 
 	return &TOTP{
 		otp:        NewOTP(config.Secret, config.HashType, config.CodeLength),
 		TimePeriod: config.TimeInterval,
+		window:     config.Window,
+		lastStep:   -1,
+		id:         config.ID,
+		observer:   config.Observer,
+		replay:     config.ReplayCache,
 	}
 }
 
 // Generate generates a TOTP for the current time interval.
 func (t *TOTP) Generate() string {
-	currentTime := time.Now()
-	timecode := t.timecode(currentTime.Unix())
-	return t.otp.Generate(int(timecode))
+	return t.GenerateAt(time.Now().Unix())
 }
 
 // GenerateAt generates a TOTP for the given Unix timestamp.
 func (t *TOTP) GenerateAt(unixTimeStamp int64) string {
 	timeCode := int(t.timecode(unixTimeStamp))
+	observe(context.Background(), t.observer, t.id, Event{Type: EventGenerate, Counter: timeCode})
 	return t.otp.Generate(timeCode)
 }
 
-// Validate validates a TOTP against the current time interval.
+// Validate validates a TOTP against the current time interval. A code is
+// only accepted once, see ValidateDetailedAt.
 func (t *TOTP) Validate(code string) bool {
-	return t.Generate() == code
+	result, _ := t.ValidateContext(context.Background(), code)
+	return result.Valid
 }
 
-// ValidateAt validates a TOTP against a given Unix timestamp.
+// ValidateAt validates a TOTP against a given Unix timestamp. A code is
+// only accepted once, see ValidateDetailedAt.
 func (t *TOTP) ValidateAt(unixTimestamp int64, code string) bool {
-	return t.GenerateAt(unixTimestamp) == code
+	result, _ := t.ValidateDetailedAt(unixTimestamp, code)
+	return result.Valid
+}
+
+// ValidateDetailed validates a TOTP against the current time interval and
+// reports the matched time step, its drift offset and why the code was rejected.
+func (t *TOTP) ValidateDetailed(code string) (ValidationResult, error) {
+	return t.ValidateContext(context.Background(), code)
+}
+
+// ValidateContext validates a TOTP against the current time interval like
+// ValidateDetailed. The RequestInfo carried by ctx is added to the events sent
+// to the observer. If ctx is canceled before the code is matched, the code is
+// not consumed and ctx.Err() is returned. An error of the ReplayCache is
+// returned as is and the code is not accepted.
+func (t *TOTP) ValidateContext(ctx context.Context, code string) (ValidationResult, error) {
+	return t.ValidateContextAt(ctx, time.Now().Unix(), code)
+}
+
+// ValidateDetailedAt validates a TOTP against a given Unix timestamp.
+// Time steps within the configured window are checked closest first.
+// As recommended in RFC 6238 section 5.2 a code is only accepted once,
+// codes for a time step at or before the last accepted one are rejected as replayed.
+//
+// The window is centered on the estimated clock drift of the client. When a code
+// matches away from the center the drift estimate is updated, see RFC 6238 section 6.
+// The reported Offset is the total drift between the matched and the current time step.
+func (t *TOTP) ValidateDetailedAt(unixTimestamp int64, code string) (ValidationResult, error) {
+	return t.ValidateContextAt(context.Background(), unixTimestamp, code)
+}
+
+// ValidateContextAt validates a TOTP against a given Unix timestamp like
+// ValidateDetailedAt, with the context handling of ValidateContext.
+func (t *TOTP) ValidateContextAt(ctx context.Context, unixTimestamp int64, code string) (ValidationResult, error) {
+	return t.validate(ctx, unixTimestamp, code, t.otp.Generate)
+}
+
+// validate checks code against the time steps in the window, computing the
+// code of a time step with generate, and notifies the observer.
+func (t *TOTP) validate(ctx context.Context, unixTimestamp int64, code string, generate func(step int) string) (ValidationResult, error) {
+	t.mu.Lock()
+	drift := t.drift
+	result, err := t.match(ctx, unixTimestamp, code, generate)
+	resynced := t.drift != drift
+	t.mu.Unlock()
+
+	if !result.Valid && result.Reason == ReasonNone {
+		return result, err // canceled or the replay cache failed, the code was neither accepted nor rejected
+	}
+	observeValidation(ctx, t.observer, t.id, result)
+	if resynced {
+		observe(ctx, t.observer, t.id, Event{Type: EventResync, Counter: result.Counter, Offset: result.Offset})
+	}
+	return result, err
+}
+
+// match finds the time step in the window whose code is code. It must be
+// called with mu held.
+func (t *TOTP) match(ctx context.Context, unixTimestamp int64, code string, generate func(step int) string) (ValidationResult, error) {
+	step := t.timecode(unixTimestamp)
+	if len(code) != t.otp.CodeLength {
+		return reject(step, ReasonMalformedCode)
+	}
+
+	for _, offset := range windowOffsets(t.window) {
+		if err := ctx.Err(); err != nil {
+			return ValidationResult{Counter: step}, err
+		}
+		candidate := step + t.drift + offset
+		if !equalCodes(generate(candidate), code) {
+			continue
+		}
+
+		if candidate <= t.lastStep {
+			return reject(candidate, ReasonReplayedCode)
+		}
+		if t.replay != nil {
+			unused, err := t.replay.Use(ctx, t.id, candidate, t.replayTTL())
+			if err != nil {
+				return ValidationResult{Counter: candidate}, err
+			}
+			if !unused {
+				return reject(candidate, ReasonReplayedCode)
+			}
+		}
+
+		t.lastStep = candidate
+		if offset != 0 {
+			t.drift = candidate - step
+		}
+		return ValidationResult{Valid: true, Counter: candidate, Offset: candidate - step, Advanced: true}, nil
+	}
+
+	return reject(step, ReasonInvalidCode)
+}
+
+// TOTPState holds the validation state of a TOTP that changes over time.
+// It can be persisted and restored with SetState between validations.
+type TOTPState struct {
+	Drift    int `json:"drift"`     // Drift is the estimated client clock drift in time steps.
+	LastStep int `json:"last_step"` // LastStep is the most recent time step accepted, -1 if none.
+}
+
+// State returns the current validation state of the TOTP.
+func (t *TOTP) State() TOTPState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return TOTPState{Drift: t.drift, LastStep: t.lastStep}
+}
+
+// SetState restores a validation state previously returned by State.
+func (t *TOTP) SetState(state TOTPState) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.drift = state.Drift
+	t.lastStep = state.LastStep
+}
+
+// CodeLength returns the length of the codes generated by the TOTP.
+func (t *TOTP) CodeLength() int {
+	return t.otp.CodeLength
 }
 
 // URI generates the URI for the TOTP according to the Google Authenticator Key URI Format.
@@ -72,11 +219,43 @@ func (t *TOTP) URI(label string, issuer string) string {
 		encodedLabel,
 		encodedSecret,
 		encodedIssuer,
-		t.otp.HashType,
+		url.QueryEscape(string(t.otp.HashType)),
 		t.otp.CodeLength)
 }
 
+// Key returns the parameters of the TOTP together with its label and issuer.
+func (t *TOTP) Key(label string, issuer string) Key {
+	return Key{
+		Type:       "totp",
+		Label:      label,
+		Issuer:     issuer,
+		Secret:     t.otp.secret,
+		HashType:   t.otp.HashType,
+		CodeLength: t.otp.CodeLength,
+		Period:     t.TimePeriod,
+	}
+}
+
 // timecode calculates the timecode based on the provided Unix timestamp and the TimePeriod.
 func (t *TOTP) timecode(unixTimeStamp int64) int {
 	return int(unixTimeStamp) / t.TimePeriod
 }
+
+// replayTTL returns how long a used time step must be remembered, the time
+// it can take for it to leave the window even if the drift estimate changes.
+func (t *TOTP) replayTTL() time.Duration {
+	drift := t.drift
+	if drift < 0 {
+		drift = -drift
+	}
+	return time.Duration((2*(t.window+drift)+2)*t.TimePeriod) * time.Second
+}
+
+// windowOffsets returns the offsets 0, -1, 1, -2, 2, ... up to size.
+func windowOffsets(size int) []int {
+	offsets := []int{0}
+	for i := 1; i <= size; i++ {
+		offsets = append(offsets, -i, i)
+	}
+	return offsets
+}
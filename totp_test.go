@@ -1,7 +1,10 @@
 package basicOTP_test
 
 import (
+	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
 
 	"github.com/sebastian-mora/basicOTP"
@@ -171,3 +174,118 @@ func TestTOTPDefaultTimeInterval(t *testing.T) {
 		t.Error("TOPT default time period was not set to 30 seconds")
 	}
 }
+
+func TestTOTPValidateDetailed(t *testing.T) {
+	totp := basicOTP.NewTOTP(basicOTP.TOTPConfig{
+		TimeInterval: 30,
+		CodeLength:   4,
+		HashType:     basicOTP.SHA256,
+		Secret:       []byte("TEST"),
+		Window:       1,
+	})
+
+	// "0133" is the code for 1706984502, validate it one time step later
+	result, err := totp.ValidateDetailedAt(1706984502+30, "0133")
+	if err != nil || !result.Valid || result.Offset != -1 || result.Counter != 1706984502/30 {
+		t.Errorf("Unexpected result for drifted code: %+v, %v", result, err)
+	}
+
+	result, err = totp.ValidateDetailedAt(1706984502, "0133")
+	if !errors.Is(err, basicOTP.ErrReplayedCode) || result.Valid {
+		t.Errorf("Expected replayed code, Got: %+v, %v", result, err)
+	}
+
+	// Three time steps away is outside the window centered on the drift
+	result, err = totp.ValidateDetailedAt(1706984600+120, "1183")
+	if !errors.Is(err, basicOTP.ErrInvalidCode) || result.Reason != basicOTP.ReasonInvalidCode {
+		t.Errorf("Expected invalid code, Got: %+v, %v", result, err)
+	}
+
+	_, err = totp.ValidateDetailedAt(1706984502, "01334")
+	if !errors.Is(err, basicOTP.ErrMalformedCode) {
+		t.Errorf("Expected malformed code, Got: %v", err)
+	}
+}
+
+func TestTOTPDriftTracking(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	server := basicOTP.NewTOTP(basicOTP.TOTPConfig{Secret: secret, Window: 1})
+	token := basicOTP.NewTOTP(basicOTP.TOTPConfig{Secret: secret})
+
+	// The token clock gains one second per hour, over a year that is more
+	// than 4 hours or roughly 290 time steps. Log in twice a day.
+	start := int64(1706984502)
+	for hour := int64(0); hour < 365*24; hour += 12 {
+		now := start + hour*3600
+		tokenTime := now + hour
+
+		code := token.GenerateAt(tokenTime)
+		result, err := server.ValidateDetailedAt(now, code)
+		if err != nil {
+			t.Fatalf("Validation failed after %d hours: %v", hour, err)
+		}
+
+		expectedOffset := int(tokenTime/30 - now/30)
+		if result.Offset != expectedOffset {
+			t.Fatalf("Expected offset %d, Got: %d", expectedOffset, result.Offset)
+		}
+	}
+
+	if server.State().Drift < 250 {
+		t.Errorf("Drift estimate was not updated, Got: %d", server.State().Drift)
+	}
+}
+
+func TestTOTPStateRestore(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	config := basicOTP.TOTPConfig{Secret: secret, Window: 1}
+	token := basicOTP.NewTOTP(basicOTP.TOTPConfig{Secret: secret})
+
+	now := int64(1706984502)
+	server := basicOTP.NewTOTP(config)
+	server.SetState(basicOTP.TOTPState{Drift: 10, LastStep: -1})
+
+	// A token 10 steps ahead is only accepted with the restored drift
+	code := token.GenerateAt(now + 300)
+	if basicOTP.NewTOTP(config).ValidateAt(now, code) {
+		t.Error("Code outside the window was accepted without drift")
+	}
+	if !server.ValidateAt(now, code) {
+		t.Error("Code was not accepted with restored drift")
+	}
+
+	state := server.State()
+	if state.Drift != 10 || state.LastStep != int(now+300)/30 {
+		t.Errorf("Unexpected state: %+v", state)
+	}
+
+	restored := basicOTP.NewTOTP(config)
+	restored.SetState(state)
+	if restored.ValidateAt(now, code) {
+		t.Error("Replayed code was accepted after restoring state")
+	}
+}
+
+func TestTOTPConcurrentValidation(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	totp := basicOTP.NewTOTP(basicOTP.TOTPConfig{Secret: secret, Window: 1})
+	code := basicOTP.NewTOTP(basicOTP.TOTPConfig{Secret: secret}).GenerateAt(1706984520)
+
+	var wg sync.WaitGroup
+	var accepted atomic.Int32
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if totp.ValidateAt(1706984520, code) {
+				accepted.Add(1)
+			}
+			totp.State()
+		}()
+	}
+	wg.Wait()
+
+	if accepted.Load() != 1 {
+		t.Errorf("Expected the code to be accepted once, got %d", accepted.Load())
+	}
+}
@@ -0,0 +1,112 @@
+package basicOTP
+
+import (
+	"context"
+	"encoding/binary"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Transaction holds the details a transaction code is bound to, as required
+// for dynamic linking by PSD2. A code generated for one transaction is not
+// valid for another that differs in any field.
+type Transaction struct {
+	Amount   int64             // Amount is the amount in minor units of Currency, such as cents.
+	Currency string            // Currency is the ISO 4217 currency code, compared case-insensitively.
+	Payee    string            // Payee identifies the recipient, such as an IBAN or merchant ID.
+	Fields   map[string]string // Fields are additional details to bind the code to.
+}
+
+// Canonical returns the canonical encoding of the transaction.
+// Amount is encoded as 8 bytes, followed by the upper case Currency and the
+// Payee, the number of Fields and the Fields sorted by name. Strings are
+// prefixed with their length as 4 bytes, all integers are big-endian.
+func (tx Transaction) Canonical() []byte {
+	encoded := binary.BigEndian.AppendUint64(nil, uint64(tx.Amount))
+	encoded = appendString(encoded, strings.ToUpper(strings.TrimSpace(tx.Currency)))
+	encoded = appendString(encoded, strings.TrimSpace(tx.Payee))
+
+	names := make([]string, 0, len(tx.Fields))
+	for name := range tx.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	encoded = binary.BigEndian.AppendUint32(encoded, uint32(len(names)))
+	for _, name := range names {
+		encoded = appendString(encoded, name)
+		encoded = appendString(encoded, tx.Fields[name])
+	}
+	return encoded
+}
+
+// TransactionMessage returns the HMAC message for a counter or time step
+// bound to tx, the 8 byte counter followed by the canonical transaction.
+func TransactionMessage(input int, tx Transaction) []byte {
+	return append(CounterMessage(input), tx.Canonical()...)
+}
+
+// GenerateTransaction generates a TOTP bound to tx for the current time interval.
+func (t *TOTP) GenerateTransaction(tx Transaction) string {
+	return t.GenerateTransactionAt(time.Now().Unix(), tx)
+}
+
+// GenerateTransactionAt generates a TOTP bound to tx for the given Unix timestamp.
+func (t *TOTP) GenerateTransactionAt(unixTimestamp int64, tx Transaction) string {
+	step := t.timecode(unixTimestamp)
+	observe(context.Background(), t.observer, t.id, Event{Type: EventGenerate, Counter: step})
+	return t.otp.GenerateMessage(TransactionMessage(step, tx))
+}
+
+// ValidateTransaction validates a TOTP bound to tx against the current time interval.
+func (t *TOTP) ValidateTransaction(code string, tx Transaction) (ValidationResult, error) {
+	return t.ValidateTransactionContext(context.Background(), code, tx)
+}
+
+// ValidateTransactionContext validates a TOTP bound to tx against the current
+// time interval, with the context handling of ValidateContext.
+func (t *TOTP) ValidateTransactionContext(ctx context.Context, code string, tx Transaction) (ValidationResult, error) {
+	return t.ValidateTransactionContextAt(ctx, time.Now().Unix(), code, tx)
+}
+
+// ValidateTransactionAt validates a TOTP bound to tx against a given Unix timestamp.
+// The window, drift and replay protection of ValidateDetailedAt apply, a time
+// step used by a transaction code can not be used again by any other code.
+func (t *TOTP) ValidateTransactionAt(unixTimestamp int64, code string, tx Transaction) (ValidationResult, error) {
+	return t.ValidateTransactionContextAt(context.Background(), unixTimestamp, code, tx)
+}
+
+// ValidateTransactionContextAt validates a TOTP bound to tx against a given
+// Unix timestamp, with the context handling of ValidateContext.
+func (t *TOTP) ValidateTransactionContextAt(ctx context.Context, unixTimestamp int64, code string, tx Transaction) (ValidationResult, error) {
+	return t.validate(ctx, unixTimestamp, code, func(step int) string {
+		return t.otp.GenerateMessage(TransactionMessage(step, tx))
+	})
+}
+
+// GenerateTransaction returns a HOTP code bound to tx, incrementing the counter.
+func (h *HTOP) GenerateTransaction(tx Transaction) string {
+	counter := h.next()
+	observe(context.Background(), h.observer, h.id, Event{Type: EventGenerate, Counter: counter})
+	return h.otp.GenerateMessage(TransactionMessage(counter, tx))
+}
+
+// ValidateTransaction validates a HOTP code bound to tx like ValidateDetailed.
+func (h *HTOP) ValidateTransaction(input string, tx Transaction) (ValidationResult, error) {
+	return h.ValidateTransactionContext(context.Background(), input, tx)
+}
+
+// ValidateTransactionContext validates a HOTP code bound to tx, with the
+// context handling of ValidateContext.
+func (h *HTOP) ValidateTransactionContext(ctx context.Context, input string, tx Transaction) (ValidationResult, error) {
+	return h.validate(ctx, input, func(counter int) string {
+		return h.otp.GenerateMessage(TransactionMessage(counter, tx))
+	})
+}
+
+// appendString appends s prefixed with its length.
+func appendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(s)))
+	return append(b, s...)
+}
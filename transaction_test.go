@@ -0,0 +1,101 @@
+package basicOTP_test
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/sebastian-mora/basicOTP"
+)
+
+var (
+	toAlice   = basicOTP.Transaction{Amount: 1000, Currency: "EUR", Payee: "DE89370400440532013000"}
+	toMallory = basicOTP.Transaction{Amount: 100000, Currency: "EUR", Payee: "GB82WEST12345698765432"}
+	withRef   = basicOTP.Transaction{Amount: 1000, Currency: "EUR", Payee: "DE89370400440532013000", Fields: map[string]string{"reference": "INV-42"}}
+)
+
+/*
+Test vectors were computed independently with HMAC-SHA1 over the 8 byte
+counter followed by the canonical transaction, using the RFC 4226 secret
+"12345678901234567890".
+*/
+var transactionTestCases = []struct {
+	Counter int
+	Alice   string
+	Mallory string
+	WithRef string
+}{
+	{0, "389935", "822179", "256076"},
+	{1, "702175", "130486", "107491"},
+	{2, "465797", "682158", "857860"},
+}
+
+func TestTransactionCanonical(t *testing.T) {
+	expected := "00000000000003e8" + "00000003455552" + "00000016" + hex.EncodeToString([]byte("DE89370400440532013000")) + "00000000"
+	if encoded := hex.EncodeToString(toAlice.Canonical()); encoded != expected {
+		t.Errorf("Expected %s, got %s", expected, encoded)
+	}
+
+	normalized := basicOTP.Transaction{Amount: 1000, Currency: " eur", Payee: "DE89370400440532013000 "}
+	if hex.EncodeToString(normalized.Canonical()) != expected {
+		t.Error("Expected currency case and surrounding spaces to be ignored")
+	}
+}
+
+func TestHOTPTransactionVectors(t *testing.T) {
+	for _, tc := range transactionTestCases {
+		for name, pair := range map[string]struct {
+			tx       basicOTP.Transaction
+			expected string
+		}{
+			"alice":   {toAlice, tc.Alice},
+			"mallory": {toMallory, tc.Mallory},
+			"ref":     {withRef, tc.WithRef},
+		} {
+			hotp := basicOTP.NewHTOP(basicOTP.HOTPConfig{CodeLength: 6, HashType: basicOTP.SHA1, Secret: []byte("12345678901234567890"), Counter: tc.Counter})
+			if code := hotp.GenerateTransaction(pair.tx); code != pair.expected {
+				t.Errorf("Counter %d %s: expected %s, got %s", tc.Counter, name, pair.expected, code)
+			}
+		}
+	}
+}
+
+func TestHOTPValidateTransaction(t *testing.T) {
+	hotp := basicOTP.NewHTOP(basicOTP.HOTPConfig{CodeLength: 6, HashType: basicOTP.SHA1, Secret: []byte("12345678901234567890"), SynchronizationLimit: 3})
+
+	if _, err := hotp.ValidateTransaction("389935", toMallory); !errors.Is(err, basicOTP.ErrInvalidCode) {
+		t.Errorf("Expected code for another transaction to be rejected, got %v", err)
+	}
+	if hotp.Validate("389935") {
+		t.Error("Expected transaction code to be rejected as a plain HOTP code")
+	}
+
+	result, err := hotp.ValidateTransaction("702175", toAlice)
+	if err != nil || result.Counter != 1 || result.Offset != 1 {
+		t.Fatalf("Expected code to match counter 1, got %+v, %v", result, err)
+	}
+}
+
+func TestTOTPTransaction(t *testing.T) {
+	timestamp := int64(1706984520)
+	totp := basicOTP.NewTOTP(basicOTP.TOTPConfig{CodeLength: 6, HashType: basicOTP.SHA1, Secret: []byte("12345678901234567890"), Window: 1})
+
+	for tx, expected := range map[*basicOTP.Transaction]string{&toAlice: "906085", &toMallory: "515949", &withRef: "756640"} {
+		if code := totp.GenerateTransactionAt(timestamp, *tx); code != expected {
+			t.Errorf("Expected %s, got %s", expected, code)
+		}
+	}
+
+	if _, err := totp.ValidateTransactionAt(timestamp, "906085", toMallory); !errors.Is(err, basicOTP.ErrInvalidCode) {
+		t.Errorf("Expected code for another transaction to be rejected, got %v", err)
+	}
+
+	result, err := totp.ValidateTransactionAt(timestamp+30, "906085", toAlice)
+	if err != nil || result.Counter != 56899484 || result.Offset != -1 {
+		t.Fatalf("Expected code to match the previous time step, got %+v, %v", result, err)
+	}
+
+	if _, err := totp.ValidateTransactionAt(timestamp+30, "906085", toAlice); !errors.Is(err, basicOTP.ErrReplayedCode) {
+		t.Errorf("Expected replayed code to be rejected, got %v", err)
+	}
+}
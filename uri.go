@@ -0,0 +1,143 @@
+package basicOTP
+
+import (
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Key holds the parameters of an OTP account as found in a Key URI.
+type Key struct {
+	Type       string   `json:"type"`              // Type is "totp", "hotp" or "hybrid".
+	Label      string   `json:"label"`             // Label identifies the account, usually "issuer:account".
+	Issuer     string   `json:"issuer,omitempty"`  // Issuer is the provider or service the account belongs to.
+	Secret     []byte   `json:"secret"`            // Secret is the shared secret key.
+	HashType   HashType `json:"algorithm"`         // HashType is the hash algorithm used for OTP generation.
+	CodeLength int      `json:"digits"`            // CodeLength is the length of the generated OTP code.
+	Counter    int      `json:"counter,omitempty"` // Counter is the initial counter value of a HOTP key.
+	Period     int      `json:"period,omitempty"`  // Period is the time period in seconds of a TOTP or hybrid key.
+	Expiry     int      `json:"expiry,omitempty"`  // Expiry is how long in seconds a code of a hybrid key remains valid.
+}
+
+// ParseURI parses a URI in the Google Authenticator Key URI Format.
+// The algorithm parameter accepts any HashType registered with RegisterHash.
+// See: https://github.com/google/google-authenticator/wiki/Key-Uri-Format
+func ParseURI(uri string) (Key, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return Key{}, err
+	}
+
+	if u.Scheme != "otpauth" {
+		return Key{}, fmt.Errorf("basicOTP: unsupported URI scheme %q", u.Scheme)
+	}
+
+	key := Key{Type: strings.ToLower(u.Host)}
+	if key.Type != "totp" && key.Type != "hotp" && key.Type != "hybrid" {
+		return Key{}, fmt.Errorf("basicOTP: unsupported OTP type %q", u.Host)
+	}
+
+	key.Label = strings.TrimPrefix(u.Path, "/")
+	query := u.Query()
+	key.Issuer = query.Get("issuer")
+
+	key.Secret, err = decodeSecret(query.Get("secret"))
+	if err != nil {
+		return Key{}, err
+	}
+
+	key.HashType = SHA1
+	if algorithm := query.Get("algorithm"); algorithm != "" {
+		hashType, ok := parseHashType(algorithm)
+		if !ok {
+			return Key{}, fmt.Errorf("basicOTP: unsupported algorithm %q", algorithm)
+		}
+		key.HashType = hashType
+	}
+
+	if key.CodeLength, err = intParam(query, "digits", 6); err != nil {
+		return Key{}, err
+	}
+	defaultPeriod := 30
+	if key.Type == "hybrid" {
+		defaultPeriod = 60
+		if key.Expiry, err = intParam(query, "expiry", 300); err != nil {
+			return Key{}, err
+		}
+	}
+	if key.Period, err = intParam(query, "period", defaultPeriod); err != nil {
+		return Key{}, err
+	}
+	if key.Counter, err = intParam(query, "counter", 0); err != nil {
+		return Key{}, err
+	}
+
+	if key.Type != "totp" && !query.Has("counter") {
+		return Key{}, fmt.Errorf("basicOTP: %s URI requires a counter", key.Type)
+	}
+
+	return key, nil
+}
+
+// TOTP creates a TOTP generator from the key.
+func (k Key) TOTP() *TOTP {
+	return NewTOTP(TOTPConfig{
+		TimeInterval: k.Period,
+		CodeLength:   k.CodeLength,
+		HashType:     k.HashType,
+		Secret:       k.Secret,
+	})
+}
+
+// HOTP creates a HOTP generator from the key.
+func (k Key) HOTP() *HTOP {
+	return NewHTOP(HOTPConfig{
+		CodeLength: k.CodeLength,
+		HashType:   k.HashType,
+		Secret:     k.Secret,
+		Counter:    k.Counter,
+	})
+}
+
+// Hybrid creates a hybrid event and time based generator from the key.
+func (k Key) Hybrid() *HybridOTP {
+	return NewHybridOTP(HybridConfig{
+		CodeLength: k.CodeLength,
+		HashType:   k.HashType,
+		Secret:     k.Secret,
+		Counter:    k.Counter,
+		TimeStep:   k.Period,
+		Expiry:     k.Expiry,
+	})
+}
+
+// decodeSecret decodes a Base32 secret with or without padding.
+func decodeSecret(secret string) ([]byte, error) {
+	secret = strings.TrimRight(strings.ToUpper(strings.ReplaceAll(secret, " ", "")), "=")
+	if secret == "" {
+		return nil, errors.New("basicOTP: URI requires a secret")
+	}
+
+	decoded, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		return nil, fmt.Errorf("basicOTP: invalid secret: %w", err)
+	}
+	return decoded, nil
+}
+
+// intParam reads a positive integer query parameter, returning def if it is not set.
+func intParam(query url.Values, name string, def int) (int, error) {
+	value := query.Get(name)
+	if value == "" {
+		return def, nil
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 0 {
+		return 0, fmt.Errorf("basicOTP: invalid %s %q", name, value)
+	}
+	return parsed, nil
+}
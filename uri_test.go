@@ -0,0 +1,89 @@
+package basicOTP_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/sebastian-mora/basicOTP"
+)
+
+func TestParseURITOTP(t *testing.T) {
+	key, err := basicOTP.ParseURI("otpauth://totp/Example:alice@google.com?secret=JBSWY3DPEHPK3PXP&issuer=Example&algorithm=SHA384&digits=8&period=60")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if key.Type != "totp" || key.Label != "Example:alice@google.com" || key.Issuer != "Example" {
+		t.Errorf("Unexpected key metadata: %+v", key)
+	}
+	if !bytes.Equal(key.Secret, []byte("Hello!\xde\xad\xbe\xef")) {
+		t.Errorf("Unexpected secret: %x", key.Secret)
+	}
+	if key.HashType != basicOTP.SHA384 || key.CodeLength != 8 || key.Period != 60 {
+		t.Errorf("Unexpected key parameters: %+v", key)
+	}
+
+	totp := key.TOTP()
+	if totp.TimePeriod != 60 || len(totp.Generate()) != 8 {
+		t.Errorf("TOTP was not configured from the key")
+	}
+}
+
+func TestParseURIHOTPRoundTrip(t *testing.T) {
+	hotp := basicOTP.NewHTOP(basicOTP.HOTPConfig{
+		CodeLength: 6,
+		HashType:   basicOTP.SHA512_256,
+		Secret:     []byte("12345678901234567890"),
+		Counter:    7,
+	})
+
+	key, err := basicOTP.ParseURI(hotp.URI("alice", "Example"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if key.Type != "hotp" || key.HashType != basicOTP.SHA512_256 || key.Counter != 7 {
+		t.Errorf("Unexpected key: %+v", key)
+	}
+	if key.HOTP().Generate() != hotp.Generate() {
+		t.Error("Parsed HOTP does not generate the same code")
+	}
+}
+
+func TestParseURIRegisteredHash(t *testing.T) {
+	basicOTP.RegisterHash("SHA2-256", sha256.New)
+
+	totp := basicOTP.NewTOTP(basicOTP.TOTPConfig{HashType: "SHA2-256", Secret: []byte("Hello!")})
+	key, err := basicOTP.ParseURI(totp.URI("alice", "Example"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Algorithm names are matched case-insensitively
+	if _, err := basicOTP.ParseURI("otpauth://totp/alice?secret=JBSWY3DPEE&algorithm=sha2-256"); err != nil {
+		t.Error(err)
+	}
+
+	if key.HashType != "SHA2-256" {
+		t.Errorf("Expected hash type SHA2-256, Got: %s", key.HashType)
+	}
+}
+
+func TestParseURIErrors(t *testing.T) {
+	uris := []string{
+		"https://totp/alice?secret=JBSWY3DPEE",
+		"otpauth://motp/alice?secret=JBSWY3DPEE",
+		"otpauth://totp/alice",
+		"otpauth://totp/alice?secret=not-base32!",
+		"otpauth://totp/alice?secret=JBSWY3DPEE&algorithm=MD5",
+		"otpauth://totp/alice?secret=JBSWY3DPEE&digits=six",
+		"otpauth://hotp/alice?secret=JBSWY3DPEE",
+	}
+
+	for _, uri := range uris {
+		if _, err := basicOTP.ParseURI(uri); err == nil {
+			t.Errorf("Expected an error parsing %s", uri)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package basicOTP
+
+import (
+	"crypto/subtle"
+	"errors"
+)
+
+// RejectReason describes why a code was rejected during validation.
+type RejectReason int
+
+const (
+	ReasonNone          RejectReason = iota // ReasonNone indicates the code was accepted.
+	ReasonMalformedCode                     // ReasonMalformedCode indicates the code does not have the configured length.
+	ReasonInvalidCode                       // ReasonInvalidCode indicates the code did not match any counter or time step in the window.
+	ReasonReplayedCode                      // ReasonReplayedCode indicates the code matched a counter or time step that was already used.
+	ReasonRateLimited                       // ReasonRateLimited indicates too many attempts were made and the code was not checked.
+)
+
+// String returns a human readable description of the reason.
+func (r RejectReason) String() string {
+	switch r {
+	case ReasonNone:
+		return "accepted"
+	case ReasonMalformedCode:
+		return "malformed code"
+	case ReasonInvalidCode:
+		return "invalid code"
+	case ReasonReplayedCode:
+		return "replayed code"
+	case ReasonRateLimited:
+		return "rate limited"
+	default:
+		return "unknown reason"
+	}
+}
+
+// ValidationError is returned by ValidateDetailed when a code is rejected.
+// Use errors.Is with the Err* values or errors.As to inspect the Reason.
+type ValidationError struct {
+	Reason RejectReason // Reason is why the code was rejected.
+}
+
+// Error implements the error interface.
+func (e *ValidationError) Error() string {
+	return "basicOTP: " + e.Reason.String()
+}
+
+// Is reports whether target is a ValidationError with the same Reason.
+func (e *ValidationError) Is(target error) bool {
+	var t *ValidationError
+	if !errors.As(target, &t) {
+		return false
+	}
+	return e.Reason == t.Reason
+}
+
+var (
+	ErrMalformedCode = &ValidationError{Reason: ReasonMalformedCode}
+	ErrInvalidCode   = &ValidationError{Reason: ReasonInvalidCode}
+	ErrReplayedCode  = &ValidationError{Reason: ReasonReplayedCode}
+	ErrRateLimited   = &ValidationError{Reason: ReasonRateLimited}
+)
+
+// ValidationResult describes the outcome of a detailed validation.
+type ValidationResult struct {
+	Valid    bool         // Valid reports whether the code was accepted.
+	Counter  int          // Counter is the HOTP counter or TOTP time step the code matched, or the expected one if rejected.
+	Offset   int          // Offset is the distance between the matched counter or time step and the expected one.
+	Advanced bool         // Advanced reports whether the counter or last used time step moved forward.
+	Reason   RejectReason // Reason is why the code was rejected, ReasonNone if it was accepted.
+}
+
+// reject builds a failed ValidationResult and its matching error.
+func reject(counter int, reason RejectReason) (ValidationResult, error) {
+	return ValidationResult{Counter: counter, Reason: reason}, &ValidationError{Reason: reason}
+}
+
+// equalCodes compares two codes in constant time.
+func equalCodes(a string, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
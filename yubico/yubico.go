@@ -0,0 +1,234 @@
+// Package yubico validates Yubico OTPs, the 44 character codes typed by a
+// YubiKey in Yubico OTP mode. A code is the ModHex encoded public ID followed
+// by a 16 byte token encrypted with AES-128, holding the private ID, usage and
+// session counters and a CRC16 checksum.
+//
+// Validation results use the basicOTP.ValidationResult and ValidationError
+// types so YubiKeys can be handled like HOTP tokens.
+package yubico
+
+import (
+	"crypto/aes"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/sebastian-mora/basicOTP"
+)
+
+// ModHexAlphabet maps the hexadecimal digits 0-f to keys that are in the
+// same place on most keyboard layouts.
+const ModHexAlphabet = "cbdefghijklnrtuv"
+
+const (
+	tokenLength       = 16
+	encodedLength     = tokenLength * 2
+	maxPublicIDLength = 32
+	crcResidue        = 0xf0b8 // crcResidue is the CRC16 of a token including its checksum.
+)
+
+var errInvalidModHex = errors.New("yubico: invalid ModHex")
+
+// ModHexEncode encodes data in ModHex.
+func ModHexEncode(data []byte) string {
+	encoded := make([]byte, len(data)*2)
+	for i, b := range data {
+		encoded[i*2] = ModHexAlphabet[b>>4]
+		encoded[i*2+1] = ModHexAlphabet[b&0xf]
+	}
+	return string(encoded)
+}
+
+// ModHexDecode decodes a ModHex string, ignoring case.
+func ModHexDecode(s string) ([]byte, error) {
+	if len(s)%2 != 0 {
+		return nil, errInvalidModHex
+	}
+
+	s = strings.ToLower(s)
+	decoded := make([]byte, len(s)/2)
+	for i := range decoded {
+		high := strings.IndexByte(ModHexAlphabet, s[i*2])
+		low := strings.IndexByte(ModHexAlphabet, s[i*2+1])
+		if high < 0 || low < 0 {
+			return nil, errInvalidModHex
+		}
+		decoded[i] = byte(high<<4 | low)
+	}
+	return decoded, nil
+}
+
+// Token is a decrypted Yubico OTP.
+type Token struct {
+	PublicID       string  // PublicID is the ModHex public ID prefix identifying the YubiKey.
+	PrivateID      [6]byte // PrivateID is the secret ID stored inside the encrypted token.
+	UsageCounter   uint16  // UsageCounter is incremented each time the YubiKey is powered up.
+	Timestamp      uint32  // Timestamp is a 24 bit 8Hz timer started at power up.
+	SessionCounter uint8   // SessionCounter is incremented for each code in a session.
+	Random         uint16  // Random is a random value.
+}
+
+// Decode splits otp into its public ID and token, decrypts the token with the
+// 16 byte AES key and verifies its CRC16.
+func Decode(otp string, aesKey []byte) (Token, error) {
+	if len(otp) < encodedLength || len(otp) > encodedLength+maxPublicIDLength {
+		return Token{}, errInvalidModHex
+	}
+
+	publicID, encoded := otp[:len(otp)-encodedLength], otp[len(otp)-encodedLength:]
+	if _, err := ModHexDecode(publicID); err != nil {
+		return Token{}, err
+	}
+	ciphertext, err := ModHexDecode(encoded)
+	if err != nil {
+		return Token{}, err
+	}
+
+	block, err := aes.NewCipher(aesKey)
+	if err != nil {
+		return Token{}, err
+	}
+
+	// AES-128-ECB of a single block
+	plaintext := make([]byte, tokenLength)
+	block.Decrypt(plaintext, ciphertext)
+
+	if crc16(plaintext) != crcResidue {
+		return Token{}, errors.New("yubico: CRC check failed")
+	}
+
+	token := Token{
+		PublicID:       strings.ToLower(publicID),
+		UsageCounter:   binary.LittleEndian.Uint16(plaintext[6:8]),
+		Timestamp:      uint32(plaintext[8]) | uint32(plaintext[9])<<8 | uint32(plaintext[10])<<16,
+		SessionCounter: plaintext[11],
+		Random:         binary.LittleEndian.Uint16(plaintext[12:14]),
+	}
+	copy(token.PrivateID[:], plaintext[:6])
+	return token, nil
+}
+
+// Encode encrypts the token with the 16 byte AES key and returns the OTP
+// a YubiKey would type, prefixed with the public ID.
+func (t Token) Encode(aesKey []byte) (string, error) {
+	block, err := aes.NewCipher(aesKey)
+	if err != nil {
+		return "", err
+	}
+
+	plaintext := make([]byte, tokenLength)
+	copy(plaintext, t.PrivateID[:])
+	binary.LittleEndian.PutUint16(plaintext[6:8], t.UsageCounter)
+	plaintext[8] = byte(t.Timestamp)
+	plaintext[9] = byte(t.Timestamp >> 8)
+	plaintext[10] = byte(t.Timestamp >> 16)
+	plaintext[11] = t.SessionCounter
+	binary.LittleEndian.PutUint16(plaintext[12:14], t.Random)
+	binary.LittleEndian.PutUint16(plaintext[14:16], ^crc16(plaintext[:14]))
+
+	ciphertext := make([]byte, tokenLength)
+	block.Encrypt(ciphertext, plaintext)
+	return t.PublicID + ModHexEncode(ciphertext), nil
+}
+
+// counter combines the usage and session counters into a single increasing value.
+func (t Token) counter() int {
+	return int(t.UsageCounter)<<8 | int(t.SessionCounter)
+}
+
+// State holds the counters of the last accepted OTP.
+type State struct {
+	UsageCounter   uint16 `json:"usage_counter"`
+	SessionCounter uint8  `json:"session_counter"`
+}
+
+// CredentialConfig holds configuration parameters for a YubiKey credential.
+type CredentialConfig struct {
+	PublicID  string  // PublicID is the ModHex public ID of the YubiKey.
+	PrivateID [6]byte // PrivateID is the private ID programmed into the YubiKey.
+	AESKey    []byte  // AESKey is the 16 byte AES-128 key programmed into the YubiKey.
+	State     State   // State holds the counters of the last accepted OTP.
+}
+
+// Credential validates the OTPs of a single YubiKey.
+type Credential struct {
+	mu        sync.Mutex
+	publicID  string
+	privateID [6]byte
+	aesKey    []byte
+	state     State
+}
+
+// NewCredential creates a credential based on the provided configuration.
+func NewCredential(config CredentialConfig) *Credential {
+	if len(config.AESKey) != 16 {
+		panic("yubico requires a 16 byte AES key")
+	}
+
+	return &Credential{
+		publicID:  strings.ToLower(config.PublicID),
+		privateID: config.PrivateID,
+		aesKey:    config.AESKey,
+		state:     config.State,
+	}
+}
+
+// Validate reports whether otp is a valid OTP of the YubiKey.
+func (c *Credential) Validate(otp string) bool {
+	result, _ := c.ValidateDetailed(otp)
+	return result.Valid
+}
+
+// ValidateDetailed validates otp and reports the combined usage and session
+// counter it carries and how far it moved past the last accepted OTP.
+// OTPs whose counters are not greater than the stored ones are rejected as replayed.
+func (c *Credential) ValidateDetailed(otp string) (basicOTP.ValidationResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	last := int(c.state.UsageCounter)<<8 | int(c.state.SessionCounter)
+	token, err := Decode(otp, c.aesKey)
+	if errors.Is(err, errInvalidModHex) {
+		return rejected(last, basicOTP.ReasonMalformedCode)
+	}
+	if err != nil || token.PublicID != c.publicID || subtle.ConstantTimeCompare(token.PrivateID[:], c.privateID[:]) != 1 {
+		return rejected(last, basicOTP.ReasonInvalidCode)
+	}
+
+	if token.counter() <= last {
+		return rejected(token.counter(), basicOTP.ReasonReplayedCode)
+	}
+
+	c.state = State{UsageCounter: token.UsageCounter, SessionCounter: token.SessionCounter}
+	return basicOTP.ValidationResult{Valid: true, Counter: token.counter(), Offset: token.counter() - last - 1, Advanced: true}, nil
+}
+
+// State returns the counters of the last accepted OTP.
+func (c *Credential) State() State {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state
+}
+
+// rejected builds a failed result and its matching error.
+func rejected(counter int, reason basicOTP.RejectReason) (basicOTP.ValidationResult, error) {
+	return basicOTP.ValidationResult{Counter: counter, Reason: reason}, &basicOTP.ValidationError{Reason: reason}
+}
+
+// crc16 computes the ISO 13239 CRC16 used by Yubico OTPs.
+func crc16(data []byte) uint16 {
+	crc := uint16(0xffff)
+	for _, b := range data {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			lsb := crc & 1
+			crc >>= 1
+			if lsb != 0 {
+				crc ^= 0x8408
+			}
+		}
+	}
+	return crc
+}
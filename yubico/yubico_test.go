@@ -0,0 +1,154 @@
+package yubico_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/sebastian-mora/basicOTP"
+	"github.com/sebastian-mora/basicOTP/yubico"
+)
+
+var (
+	aesKey, _ = hex.DecodeString("ecde18dbe76fbd0c33330f1c354871db")
+	privateID = [6]byte{0x87, 0x92, 0xeb, 0xfe, 0x26, 0xcc}
+	publicID  = "vvccccdefhij"
+)
+
+// newToken returns a token of the test YubiKey with the given counters.
+func newToken(usage uint16, session uint8) yubico.Token {
+	return yubico.Token{
+		PublicID:       publicID,
+		PrivateID:      privateID,
+		UsageCounter:   usage,
+		Timestamp:      0x12a4b3,
+		SessionCounter: session,
+		Random:         0x5cd1,
+	}
+}
+
+func TestModHex(t *testing.T) {
+	data, _ := hex.DecodeString("0123456789abcdef")
+	if encoded := yubico.ModHexEncode(data); encoded != "cbdefghijklnrtuv" {
+		t.Errorf("Expected cbdefghijklnrtuv, Got: %s", encoded)
+	}
+
+	decoded, err := yubico.ModHexDecode("CBDEFGHIJKLNRTUV")
+	if err != nil || !bytes.Equal(decoded, data) {
+		t.Errorf("Expected %x, Got: %x, %v", data, decoded, err)
+	}
+
+	for _, invalid := range []string{"abc", "cbx0"} {
+		if _, err := yubico.ModHexDecode(invalid); err == nil {
+			t.Errorf("Expected an error decoding %s", invalid)
+		}
+	}
+}
+
+func TestDecodeKnownOTP(t *testing.T) {
+	// Example OTP from the yubico-c documentation, with an 8 character public ID
+	token, err := yubico.Decode("dteffujehknhfjbrjnlnldnhcujvddbikngjrtgh", aesKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := yubico.Token{
+		PublicID:       "dteffuje",
+		PrivateID:      privateID,
+		UsageCounter:   19,
+		Timestamp:      0xc230,
+		SessionCounter: 17,
+		Random:         0x9fc8,
+	}
+	if token != expected {
+		t.Errorf("Expected %+v, Got: %+v", expected, token)
+	}
+}
+
+func TestDecodeRoundTrip(t *testing.T) {
+	token := newToken(19, 4)
+	otp, err := token.Encode(aesKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(otp) != 44 {
+		t.Errorf("Expected a 44 character OTP, Got: %d", len(otp))
+	}
+
+	decoded, err := yubico.Decode(otp, aesKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded != token {
+		t.Errorf("Expected %+v, Got: %+v", token, decoded)
+	}
+
+	// Any change to the ciphertext breaks the CRC
+	tampered := otp[:20] + "c" + otp[21:]
+	if tampered == otp {
+		tampered = otp[:20] + "b" + otp[21:]
+	}
+	if _, err := yubico.Decode(tampered, aesKey); err == nil {
+		t.Error("Expected a CRC error for a tampered OTP")
+	}
+}
+
+func TestCredentialValidate(t *testing.T) {
+	credential := yubico.NewCredential(yubico.CredentialConfig{
+		PublicID:  publicID,
+		PrivateID: privateID,
+		AESKey:    aesKey,
+		State:     yubico.State{UsageCounter: 5, SessionCounter: 2},
+	})
+
+	encode := func(token yubico.Token) string {
+		otp, err := token.Encode(aesKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return otp
+	}
+
+	// Next code in the same session
+	result, err := credential.ValidateDetailed(encode(newToken(5, 3)))
+	if err != nil || !result.Valid || result.Offset != 0 || !result.Advanced {
+		t.Errorf("Expected a valid OTP, Got: %+v, %v", result, err)
+	}
+
+	if _, err := credential.ValidateDetailed(encode(newToken(5, 3))); !errors.Is(err, basicOTP.ErrReplayedCode) {
+		t.Errorf("Expected a replayed OTP, Got: %v", err)
+	}
+
+	// A new session resets the session counter
+	if !credential.Validate(encode(newToken(6, 0))) {
+		t.Error("Expected an OTP from a new session to be valid")
+	}
+	if credential.State() != (yubico.State{UsageCounter: 6, SessionCounter: 0}) {
+		t.Errorf("Unexpected state: %+v", credential.State())
+	}
+
+	otherKey := newToken(7, 0)
+	otherKey.PublicID = "vvccccdefhik"
+	wrongPrivateID := newToken(7, 0)
+	wrongPrivateID.PrivateID = [6]byte{1, 2, 3, 4, 5, 6}
+
+	testCases := []struct {
+		name     string
+		otp      string
+		expected error
+	}{
+		{"malformed", "vvccccdefhij" + "abcd", basicOTP.ErrMalformedCode},
+		{"other public ID", encode(otherKey), basicOTP.ErrInvalidCode},
+		{"wrong private ID", encode(wrongPrivateID), basicOTP.ErrInvalidCode},
+		{"older session", encode(newToken(5, 9)), basicOTP.ErrReplayedCode},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := credential.ValidateDetailed(tc.otp); !errors.Is(err, tc.expected) {
+				t.Errorf("Expected %v, Got: %v", tc.expected, err)
+			}
+		})
+	}
+}